@@ -23,25 +23,149 @@ package logrus
 // CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 )
 
-var handlers = []func(){}
+// DefaultExitTimeout is the default time limit of the exit handlers, see
+// SetExitTimeout.
+const DefaultExitTimeout = 30 * time.Second
 
-func runHandler(handler func()) {
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Fprintln(os.Stderr, "Error: Logrus exit handler error:", err)
+type exitHandler struct {
+	name     string
+	priority int
+	seq      uint64
+	run      func(ctx context.Context) error
+}
+
+var (
+	handlersMu  sync.Mutex
+	handlers    = []*exitHandler{}
+	handlerSeq  uint64
+	exitTimeout = DefaultExitTimeout
+)
+
+// ExitHandle identifies a registered exit handler.
+type ExitHandle struct {
+	handler *exitHandler
+}
+
+// Deregister removes the exit handler, so that it doesn't run on exit. It
+// returns false if the handler had already been removed or run.
+func (h ExitHandle) Deregister() bool {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	for i, handler := range handlers {
+		if handler == h.handler {
+			handlers = append(handlers[:i], handlers[i+1:]...)
+			return true
 		}
+	}
+	return false
+}
+
+// ExitReport is the outcome of running an exit handler.
+type ExitReport struct {
+	// Name of the handler, given to RegisterExitHandlerContext or the name
+	// of the function for handlers registered with RegisterExitHandler.
+	Name     string
+	Duration time.Duration
+	// Err is the error returned by the handler. It is the error of the
+	// context when the handler timed out, or wasn't run because the
+	// deadline had passed already.
+	Err      error
+	TimedOut bool
+	// Panic is the value the handler panicked with, if it did. Err is set
+	// too then.
+	Panic interface{}
+}
+
+type byRunOrder []*exitHandler
+
+func (s byRunOrder) Len() int      { return len(s) }
+func (s byRunOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byRunOrder) Less(i, j int) bool {
+	if s[i].priority != s[j].priority {
+		return s[i].priority > s[j].priority
+	}
+	return s[i].seq > s[j].seq
+}
+
+func runHandler(ctx context.Context, handler *exitHandler) (report ExitReport) {
+	report.Name = handler.name
+	if err := ctx.Err(); err != nil {
+		report.Err = err
+		report.TimedOut = true
+		return report
+	}
+
+	start := time.Now()
+	done := make(chan ExitReport, 1)
+	go func() {
+		result := ExitReport{Name: handler.name}
+		defer func() {
+			if p := recover(); p != nil {
+				result.Panic = p
+				result.Err = fmt.Errorf("panic: %v", p)
+				done <- result
+			}
+		}()
+		result.Err = handler.run(ctx)
+		done <- result
 	}()
 
-	handler()
+	select {
+	case report = <-done:
+	case <-ctx.Done():
+		report.Err = ctx.Err()
+		report.TimedOut = true
+	}
+	report.Duration = time.Since(start)
+	return report
+}
+
+// RunExitHandlers runs the exit handlers registered so far and removes
+// them, so that each handler runs once at most. Handlers run one after the
+// other by decreasing priority, the last registered first among those of
+// the same priority. They share ctx, limited by the exit timeout: once it is
+// done, a running handler is abandoned and the others are skipped, which
+// the reports tell.
+//
+// Exit calls it before terminating the program. Graceful shutdown code can
+// call it directly, to inspect the reports.
+func RunExitHandlers(ctx context.Context) []ExitReport {
+	handlersMu.Lock()
+	run := make([]*exitHandler, len(handlers))
+	copy(run, handlers)
+	handlers = handlers[:0]
+	timeout := exitTimeout
+	handlersMu.Unlock()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	sort.Sort(byRunOrder(run))
+	reports := make([]ExitReport, len(run))
+	for i, handler := range run {
+		reports[i] = runHandler(ctx, handler)
+	}
+	return reports
 }
 
 func runHandlers() {
-	for _, handler := range handlers {
-		runHandler(handler)
+	for _, report := range RunExitHandlers(context.Background()) {
+		if report.Err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Logrus exit handler %s error: %v\n", report.Name, report.Err)
+		}
 	}
 }
 
@@ -59,6 +183,72 @@ func Exit(code int) {
 // message but also needs to gracefully shutdown. An example usecase could be
 // closing database connections, or sending a alert that the application is
 // closing.
+//
+// The handler has priority 0; see RegisterExitHandlerContext for handlers
+// which can be deregistered, or must respect the exit timeout.
 func RegisterExitHandler(handler func()) {
-	handlers = append(handlers, handler)
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	RegisterExitHandlerContext(name, 0, func(context.Context) error {
+		handler()
+		return nil
+	})
+}
+
+// RegisterExitHandlerContext adds a named exit handler, and returns a handle
+// to deregister it. Handlers of higher priority run first; those of the same
+// priority run in the reverse order of their registration, like deferred
+// calls. The handler should return once ctx is done, when the exit timeout
+// expires:
+//
+//   handle := logrus.RegisterExitHandlerContext("db", 10, func(ctx context.Context) error {
+//     return db.Shutdown(ctx)
+//   })
+//   defer handle.Deregister()
+func RegisterExitHandlerContext(name string, priority int, handler func(ctx context.Context) error) ExitHandle {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlerSeq++
+	h := &exitHandler{name: name, priority: priority, seq: handlerSeq, run: handler}
+	handlers = append(handlers, h)
+	return ExitHandle{handler: h}
+}
+
+// FlushExitPriority is the priority of the exit handlers registered by
+// RegisterExitFlusher. It is below the default priority 0, so that the
+// entries logged by the other handlers are flushed too.
+const FlushExitPriority = -100
+
+// RegisterExitFlusher registers an exit handler flushing flusher, such as an
+// asynchronous hook, so that the entries it holds are sent when logrus exits
+// through `Fatal` or `Exit`. The flush is abandoned once the exit timeout
+// expires. Deregister the returned handle when closing the flusher, so that
+// it can be garbage collected:
+//
+//   hook.exitHandle = logrus.RegisterExitFlusher("otlp hook", hook)
+//   ...
+//   func (hook *Hook) Close() error {
+//     hook.exitHandle.Deregister()
+//     ...
+//   }
+func RegisterExitFlusher(name string, flusher Flusher) ExitHandle {
+	return RegisterExitHandlerContext(name, FlushExitPriority, func(ctx context.Context) error {
+		done := make(chan error, 1)
+		go func() {
+			done <- flusher.Flush()
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// SetExitTimeout sets the time limit of the exit handlers as a whole,
+// DefaultExitTimeout by default. A zero timeout removes the limit.
+func SetExitTimeout(timeout time.Duration) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	exitTimeout = timeout
 }
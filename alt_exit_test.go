@@ -1,20 +1,253 @@
 package logrus
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
 	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// resetExitHandlers clears the exit handlers and the exit timeout for a
+// test, and returns a function restoring them:
+//
+//  defer resetExitHandlers()()
+func resetExitHandlers() func() {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	saved, savedTimeout := handlers, exitTimeout
+	handlers, exitTimeout = []*exitHandler{}, DefaultExitTimeout
+	return func() {
+		handlersMu.Lock()
+		defer handlersMu.Unlock()
+		handlers, exitTimeout = saved, savedTimeout
+	}
+}
+
 func TestRegister(t *testing.T) {
-	current := len(handlers)
+	defer resetExitHandlers()()
 	RegisterExitHandler(func() {})
-	if len(handlers) != current+1 {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if len(handlers) != 1 {
 		t.Fatalf("can't add handler")
 	}
 }
 
+func TestExitHandlersOrder(t *testing.T) {
+	defer resetExitHandlers()()
+	var order []string
+	register := func(name string, priority int) ExitHandle {
+		return RegisterExitHandlerContext(name, priority, func(context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	register("first", 0)
+	register("second", 0)
+	register("urgent", 10)
+	removed := register("removed", 0)
+	register("last", -1)
+
+	if !removed.Deregister() {
+		t.Fatalf("can't deregister handler")
+	}
+	if removed.Deregister() {
+		t.Fatalf("handler deregistered twice")
+	}
+
+	RunExitHandlers(context.Background())
+	if strings.Join(order, " ") != "urgent second first last" {
+		t.Fatalf("bad order %v", order)
+	}
+
+	// Handlers run once at most
+	order = nil
+	RunExitHandlers(context.Background())
+	if len(order) != 0 {
+		t.Fatalf("handlers ran twice: %v", order)
+	}
+}
+
+func TestExitHandlersReports(t *testing.T) {
+	defer resetExitHandlers()()
+	SetExitTimeout(50 * time.Millisecond)
+
+	failure := errors.New("failed")
+	RegisterExitHandlerContext("skipped", 0, func(context.Context) error {
+		return nil
+	})
+	RegisterExitHandlerContext("hanging", 1, func(context.Context) error {
+		select {}
+	})
+	RegisterExitHandlerContext("failing", 2, func(context.Context) error {
+		return failure
+	})
+	RegisterExitHandlerContext("panicking", 3, func(context.Context) error {
+		panic("boom")
+	})
+	RegisterExitHandlerContext("slow", 4, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("no deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	start := time.Now()
+	reports := RunExitHandlers(context.Background())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("exit handlers took %v", elapsed)
+	}
+
+	byName := make(map[string]ExitReport)
+	for _, report := range reports {
+		byName[report.Name] = report
+	}
+	if r := byName["slow"]; r.Err != nil || r.Duration < 10*time.Millisecond {
+		t.Fatalf("bad report for slow handler: %+v", r)
+	}
+	if r := byName["panicking"]; r.Panic != "boom" || r.Err == nil || r.TimedOut {
+		t.Fatalf("bad report for panicking handler: %+v", r)
+	}
+	if r := byName["failing"]; r.Err != failure || r.TimedOut {
+		t.Fatalf("bad report for failing handler: %+v", r)
+	}
+	if r := byName["hanging"]; r.Err != context.DeadlineExceeded || !r.TimedOut {
+		t.Fatalf("bad report for hanging handler: %+v", r)
+	}
+	if r := byName["skipped"]; r.Err != context.DeadlineExceeded || !r.TimedOut || r.Duration != 0 {
+		t.Fatalf("bad report for skipped handler: %+v", r)
+	}
+}
+
+type blockingFlusher chan struct{}
+
+func (f blockingFlusher) Flush() error {
+	<-f
+	return nil
+}
+
+func TestRegisterExitFlusher(t *testing.T) {
+	defer resetExitHandlers()()
+	SetExitTimeout(20 * time.Millisecond)
+
+	var order []string
+	RegisterExitHandlerContext("handler", 0, func(context.Context) error {
+		order = append(order, "handler")
+		return nil
+	})
+	flusher := make(blockingFlusher)
+	defer close(flusher)
+	RegisterExitFlusher("flusher", flusher)
+	removed := RegisterExitFlusher("removed", flusher)
+	removed.Deregister()
+
+	var flusherReport ExitReport
+	for _, report := range RunExitHandlers(context.Background()) {
+		order = append(order, report.Name)
+		if report.Name == "flusher" {
+			flusherReport = report
+		}
+	}
+	if strings.Join(order, " ") != "handler handler flusher" {
+		t.Fatalf("bad order %v", order)
+	}
+	if !flusherReport.TimedOut || flusherReport.Err != context.DeadlineExceeded {
+		t.Fatalf("blocking flush not abandoned: %+v", flusherReport)
+	}
+}
+
+type funcFlusher func() error
+
+func (f funcFlusher) Flush() error {
+	return f()
+}
+
+func TestRegisterExitFlusherError(t *testing.T) {
+	defer resetExitHandlers()()
+
+	failure := errors.New("queue stuck")
+	RegisterExitFlusher("failing", funcFlusher(func() error { return failure }))
+	RegisterExitFlusher("flushing", funcFlusher(func() error { return nil }))
+
+	reports := RunExitHandlers(context.Background())
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %+v", reports)
+	}
+	for _, report := range reports {
+		if report.TimedOut {
+			t.Fatalf("bad report for %s: %+v", report.Name, report)
+		}
+		if (report.Name == "failing") != (report.Err == failure) {
+			t.Fatalf("bad error for %s: %v", report.Name, report.Err)
+		}
+	}
+}
+
+func TestExitHandlersRace(t *testing.T) {
+	defer resetExitHandlers()()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h := RegisterExitHandlerContext("racing", j, func(context.Context) error { return nil })
+				if j%2 == 0 {
+					h.Deregister()
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		RunExitHandlers(context.Background())
+	}()
+	wg.Wait()
+	RunExitHandlers(context.Background())
+}
+
+func TestExitFunc(t *testing.T) {
+	var buffer bytes.Buffer
+	var codes []int
+	logger := New()
+	logger.Out = &buffer
+	logger.ExitFunc = func(code int) {
+		codes = append(codes, code)
+	}
+
+	logger.Fatal("fatal")
+	logger.Fatalf("fatal %d", 2)
+	logger.Fatalln("fatal", 3)
+	logger.WithField("entry", true).Fatal("fatal")
+	logger.WithField("entry", true).Fatalf("fatal %d", 5)
+	logger.WithField("entry", true).Fatalln("fatal", 6)
+	if len(codes) != 6 {
+		t.Fatalf("expected 6 exits, got %v", codes)
+	}
+	if n := strings.Count(buffer.String(), "level=fatal"); n != 6 {
+		t.Fatalf("expected 6 fatal entries, got %d", n)
+	}
+
+	// Fatal exits even when the fatal entry is filtered out
+	buffer.Reset()
+	logger.Level = PanicLevel
+	logger.Fatal("filtered")
+	logger.WithField("entry", true).Fatalf("filtered")
+	if len(codes) != 8 || codes[7] != 1 {
+		t.Fatalf("expected 8 exits, got %v", codes)
+	}
+	if buffer.Len() != 0 {
+		t.Fatalf("expected no output, got %q", buffer.String())
+	}
+}
+
 func TestHandler(t *testing.T) {
 	gofile := "/tmp/testprog.go"
 	if err := ioutil.WriteFile(gofile, testprog, 0666); err != nil {
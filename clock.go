@@ -0,0 +1,33 @@
+package logrus
+
+import "time"
+
+// A Clock tells the time of entries. Setting `Logger.Clock` to a fake one
+// makes time-dependent output reproducible, in tests or when replaying logs.
+type Clock interface {
+	// Now returns the time of an entry being logged.
+	Now() time.Time
+	// Start returns the time elapsed times are counted from, such as the
+	// seconds printed by TextFormatter when a TTY is attached.
+	Start() time.Time
+}
+
+// SystemClock is the clock of loggers without one: it tells the system time
+// and counts elapsed times from the start of the program.
+var SystemClock Clock = systemClock{}
+
+var baseTimestamp = time.Now()
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time   { return time.Now() }
+func (systemClock) Start() time.Time { return baseTimestamp }
+
+// clock returns the clock of logger, or SystemClock when it has none.
+func (logger *Logger) clock() Clock {
+	if logger == nil {
+		return SystemClock
+	}
+	clock, _ := logger.settings()
+	return clock
+}
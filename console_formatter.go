@@ -0,0 +1,280 @@
+package logrus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Color is the SGR parameter list of an ANSI escape sequence, such as "31"
+// for red or "1;33" for bold yellow. The empty Color leaves text unstyled.
+type Color string
+
+// ANSIColor returns one of the 16 basic terminal colors, given by its SGR
+// code: 30-37 for the normal and 90-97 for the bright foreground colors.
+func ANSIColor(code int) Color {
+	return Color(strconv.Itoa(code))
+}
+
+// Color256 returns a color of the 256 color palette.
+func Color256(n uint8) Color {
+	return Color("38;5;" + strconv.Itoa(int(n)))
+}
+
+// RGBColor returns a 24 bit "truecolor" color.
+func RGBColor(r, g, b uint8) Color {
+	return Color(fmt.Sprintf("38;2;%d;%d;%d", r, g, b))
+}
+
+// Bold returns the color rendered in bold.
+func (c Color) Bold() Color {
+	if c == "" {
+		return "1"
+	}
+	return "1;" + c
+}
+
+func (c Color) paint(b *bytes.Buffer, s string, enabled bool) {
+	if !enabled || c == "" {
+		b.WriteString(s)
+		return
+	}
+	b.WriteString("\x1b[")
+	b.WriteString(string(c))
+	b.WriteByte('m')
+	b.WriteString(s)
+	b.WriteString("\x1b[0m")
+}
+
+// Theme defines the colors a ConsoleFormatter uses for each part of a line.
+type Theme struct {
+	// Levels holds the color of the level label for each level. The keys of
+	// the fields use the level color as well, unless Key is set.
+	Levels    map[Level]Color
+	Timestamp Color
+	Message   Color
+	Key       Color
+	Value     Color
+	// Error is used for the values of fields holding an error, and for the
+	// field under ErrorKey.
+	Error  Color
+	Caller Color
+}
+
+// DefaultTheme returns the theme used by ConsoleFormatter when none is set.
+// It only uses the basic terminal colors, so it adapts to the palette of the
+// terminal.
+func DefaultTheme() *Theme {
+	return &Theme{
+		Levels: map[Level]Color{
+			DebugLevel: ANSIColor(gray),
+			InfoLevel:  ANSIColor(blue),
+			WarnLevel:  ANSIColor(yellow),
+			ErrorLevel: ANSIColor(red),
+			FatalLevel: ANSIColor(red).Bold(),
+			PanicLevel: ANSIColor(red).Bold(),
+		},
+		Timestamp: ANSIColor(90),
+		Error:     ANSIColor(red).Bold(),
+		Caller:    ANSIColor(90),
+	}
+}
+
+// ConsoleFormatter formats entries for humans reading them in a terminal:
+//
+//  15:04:05.000 WARNING A walrus appears              animal=walrus size=10
+//
+// Columns are aligned, fields holding an error are highlighted and values
+// spanning several lines are printed below the entry, indented. Colors
+// follow the NO_COLOR (https://no-color.org) and FORCE_COLOR conventions
+// when neither ForceColors nor DisableColors is set, and are otherwise only
+// used when a TTY is attached.
+type ConsoleFormatter struct {
+	// Theme sets the colors, DefaultTheme() is used when nil.
+	Theme *Theme
+
+	// Set to true to bypass checking for a TTY and the environment before
+	// outputting colors.
+	ForceColors bool
+
+	// Force disabling colors.
+	DisableColors bool
+
+	// Disable timestamp logging.
+	DisableTimestamp bool
+
+	// TimestampFormat to use for display. Defaults to "15:04:05.000".
+	TimestampFormat string
+
+	// MessageWidth is the width the message is padded to so that fields line
+	// up. Defaults to 40, a negative value disables padding.
+	MessageWidth int
+
+	// The fields are sorted by default for a consistent output.
+	DisableSorting bool
+
+	// QuoteEmptyFields will wrap empty fields in quotes if true.
+	QuoteEmptyFields bool
+
+	isColored bool
+	text      *TextFormatter
+
+	once sync.Once
+}
+
+func (f *ConsoleFormatter) init(entry *Entry) {
+	isTerminal := false
+	if entry.Logger != nil {
+		isTerminal = IsTerminal(entry.Logger.Out)
+	}
+	f.isColored = colorsEnabled(f.ForceColors, f.DisableColors, isTerminal)
+	if f.Theme == nil {
+		f.Theme = DefaultTheme()
+	}
+	f.text = &TextFormatter{QuoteCharacter: "\"", QuoteEmptyFields: f.QuoteEmptyFields}
+}
+
+// colorsEnabled decides whether to output colors. Explicit configuration
+// wins over the NO_COLOR and FORCE_COLOR environment variables, which win
+// over TTY detection.
+func colorsEnabled(force, disable, isTerminal bool) bool {
+	switch {
+	case disable:
+		return false
+	case force:
+		return true
+	case os.Getenv("NO_COLOR") != "":
+		return false
+	}
+	switch os.Getenv("FORCE_COLOR") {
+	case "", "0", "false":
+		return isTerminal
+	}
+	return true
+}
+
+// The widest level label, "WARNING".
+const consoleLevelWidth = 7
+
+func (f *ConsoleFormatter) Format(entry *Entry) ([]byte, error) {
+	f.once.Do(func() { f.init(entry) })
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	if !f.DisableSorting {
+		sort.Strings(keys)
+	}
+
+	theme := f.Theme
+	levelColor := theme.Levels[entry.Level]
+	keyColor := theme.Key
+	if keyColor == "" {
+		keyColor = levelColor
+	}
+
+	indent := 0
+	if !f.DisableTimestamp {
+		timestampFormat := f.TimestampFormat
+		if timestampFormat == "" {
+			timestampFormat = "15:04:05.000"
+		}
+		timestamp := entry.Time.Format(timestampFormat)
+		theme.Timestamp.paint(b, timestamp, f.isColored)
+		b.WriteByte(' ')
+		indent += utf8.RuneCountInString(timestamp) + 1
+	}
+
+	level := strings.ToUpper(entry.Level.String())
+	levelColor.paint(b, fmt.Sprintf("%-*s", consoleLevelWidth, level), f.isColored)
+	b.WriteByte(' ')
+	indent += consoleLevelWidth + 1
+
+	// Continuation lines of the message are aligned with its first line.
+	lines := strings.Split(strings.TrimRight(entry.Message, "\n"), "\n")
+	message := lines[0]
+	if len(lines) > 1 {
+		message = strings.Join(lines, "\n"+strings.Repeat(" ", indent))
+	}
+	width := f.MessageWidth
+	if width == 0 {
+		width = 40
+	}
+	if pad := width - utf8.RuneCountInString(lines[len(lines)-1]); len(keys) > 0 && pad > 0 {
+		message += strings.Repeat(" ", pad)
+	}
+	theme.Message.paint(b, message, f.isColored)
+
+	var multiline []string
+	for _, k := range keys {
+		value := f.formatValue(entry.Data[k])
+		if strings.Contains(value, "\n") {
+			multiline = append(multiline, k)
+			continue
+		}
+		b.WriteByte(' ')
+		keyColor.paint(b, k, f.isColored)
+		b.WriteByte('=')
+		f.valueColor(k, entry.Data[k]).paint(b, value, f.isColored)
+	}
+	if entry.Caller != nil {
+		b.WriteByte(' ')
+		theme.Caller.paint(b, fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line), f.isColored)
+	}
+
+	// Values spanning several lines, such as stack traces, get their own
+	// indented block below the entry.
+	for _, k := range multiline {
+		b.WriteString("\n    ")
+		keyColor.paint(b, k, f.isColored)
+		b.WriteString(": ")
+		value := strings.TrimRight(f.formatValue(entry.Data[k]), "\n")
+		value = strings.Replace(value, "\n", "\n"+strings.Repeat(" ", 4+utf8.RuneCountInString(k)+2), -1)
+		f.valueColor(k, entry.Data[k]).paint(b, value, f.isColored)
+	}
+
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// formatValue renders a single line value as TextFormatter would, and
+// multi-line ones verbatim.
+func (f *ConsoleFormatter) formatValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if strings.Contains(s, "\n") {
+		return s
+	}
+
+	var b bytes.Buffer
+	f.text.appendValue(&b, s)
+	return b.String()
+}
+
+func (f *ConsoleFormatter) valueColor(key string, v interface{}) Color {
+	if _, isError := v.(error); isError || key == ErrorKey {
+		return f.Theme.Error
+	}
+	return f.Theme.Value
+}
@@ -0,0 +1,89 @@
+package logrus
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func consoleEntry() *Entry {
+	entry := WithFields(Fields{"animal": "walrus", "size": 10})
+	entry.Time = time.Date(2017, 5, 6, 8, 48, 33, 0, time.UTC)
+	entry.Level = WarnLevel
+	entry.Message = "A walrus appears"
+	return entry
+}
+
+func TestConsoleFormatterPlain(t *testing.T) {
+	f := &ConsoleFormatter{DisableColors: true, MessageWidth: 20}
+
+	b, err := f.Format(consoleEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, "08:48:33.000 WARNING A walrus appears     animal=walrus size=10\n", string(b))
+
+	entry := consoleEntry()
+	entry.Data = Fields{}
+	entry.Level = InfoLevel
+	b, err = f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "08:48:33.000 INFO    A walrus appears\n", string(b))
+}
+
+func TestConsoleFormatterColors(t *testing.T) {
+	theme := &Theme{
+		Levels:    map[Level]Color{WarnLevel: Color256(208)},
+		Timestamp: RGBColor(1, 2, 3),
+		Value:     ANSIColor(36),
+		Error:     ANSIColor(31).Bold(),
+	}
+	f := &ConsoleFormatter{ForceColors: true, Theme: theme, MessageWidth: -1}
+
+	entry := consoleEntry()
+	entry.Data = Fields{"err": errors.New("boom")}
+	b, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[38;2;1;2;3m08:48:33.000\x1b[0m \x1b[38;5;208mWARNING\x1b[0m A walrus appears"+
+		" \x1b[38;5;208merr\x1b[0m=\x1b[1;31mboom\x1b[0m\n", string(b))
+}
+
+func TestConsoleFormatterMultiline(t *testing.T) {
+	f := &ConsoleFormatter{DisableColors: true, DisableTimestamp: true, MessageWidth: -1}
+
+	entry := consoleEntry()
+	entry.Message = "first\nsecond"
+	entry.Data = Fields{"stack": "main.main()\n\tmain.go:12\n", "user": "walrus bob"}
+	entry.Caller = &runtime.Frame{File: "/src/main.go", Line: 12}
+	b, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, "WARNING first\n"+
+		"        second user=\"walrus bob\" main.go:12\n"+
+		"    stack: main.main()\n"+
+		"           \tmain.go:12\n", string(b))
+}
+
+func TestConsoleFormatterEnvironment(t *testing.T) {
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	defer os.Setenv("FORCE_COLOR", os.Getenv("FORCE_COLOR"))
+
+	os.Setenv("NO_COLOR", "")
+	os.Setenv("FORCE_COLOR", "")
+	assert.False(t, colorsEnabled(false, false, false))
+	assert.True(t, colorsEnabled(false, false, true))
+	assert.True(t, colorsEnabled(true, false, false))
+	assert.False(t, colorsEnabled(true, true, true))
+
+	os.Setenv("NO_COLOR", "1")
+	assert.False(t, colorsEnabled(false, false, true))
+	assert.True(t, colorsEnabled(true, false, false))
+
+	os.Setenv("NO_COLOR", "")
+	os.Setenv("FORCE_COLOR", "1")
+	assert.True(t, colorsEnabled(false, false, false))
+	assert.False(t, colorsEnabled(false, true, false))
+	os.Setenv("FORCE_COLOR", "0")
+	assert.False(t, colorsEnabled(false, false, false))
+}
@@ -0,0 +1,136 @@
+package logrus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ECSVersion is the version of the Elastic Common Schema the ECSFormatter
+// output conforms to.
+const ECSVersion = "1.6.0"
+
+// ECSTimestampFormat is the default timestamp format of the ECSFormatter,
+// ISO 8601 with millisecond precision as expected by Elasticsearch.
+const ECSTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ECSFormatter formats logs into JSON following the Elastic Common Schema
+// (ECS), so that they can be ingested into Elasticsearch and used with the
+// Kibana ECS dashboards without further processing:
+//
+//  {"@timestamp":"2017-05-06T08:48:33.000Z","ecs.version":"1.6.0","log.level":"info","message":"A walrus appears","fields":{"animal":"walrus"}}
+//
+// An error added with `WithError` is reported as `error.message`,
+// `error.type` and `error.stack_trace`, and the caller, when the logger has
+// `ReportCaller` enabled, as `log.origin.*`. The trace correlation fields
+// added by TraceHook become `trace.id` and `span.id`.
+type ECSFormatter struct {
+	// TimestampFormat sets the format used for `@timestamp`. Defaults to
+	// ECSTimestampFormat.
+	TimestampFormat string
+
+	// DataKey is the key all the user fields are nested under. Defaults to
+	// "fields". Setting it to "-" merges the user fields into the top level
+	// object, which risks clashing with the ECS fields.
+	DataKey string
+
+	// DisableKeyExpansion keeps dotted user field keys such as `http.status`
+	// verbatim, instead of expanding them into nested objects.
+	DisableKeyExpansion bool
+
+	// TraceFieldMap is the FieldMap of the TraceHook adding the trace
+	// correlation fields, when it changes their keys.
+	TraceFieldMap FieldMap
+}
+
+func (f *ECSFormatter) Format(entry *Entry) ([]byte, error) {
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = ECSTimestampFormat
+	}
+
+	fields := make(map[string]interface{}, len(entry.Data))
+	var ecsError map[string]interface{}
+	var traceID, spanID interface{}
+	traceIDKey := f.TraceFieldMap.resolve(FieldKeyTraceID)
+	spanIDKey := f.TraceFieldMap.resolve(FieldKeySpanID)
+	for k, v := range entry.Data {
+		switch k {
+		case ErrorKey:
+			ecsError = ecsErrorFields(v)
+			continue
+		case traceIDKey:
+			traceID = v
+			continue
+		case spanIDKey:
+			spanID = v
+			continue
+		}
+		if err, ok := v.(error); ok {
+			// Otherwise errors are ignored by `encoding/json`
+			v = err.Error()
+		}
+		fields[k] = v
+	}
+	if !f.DisableKeyExpansion {
+		fields = expandDottedKeys(fields)
+	}
+
+	var data map[string]interface{}
+	switch f.DataKey {
+	case "-":
+		data = fields
+	case "":
+		data = map[string]interface{}{"fields": fields}
+	default:
+		data = map[string]interface{}{f.DataKey: fields}
+	}
+	if len(fields) == 0 {
+		data = make(map[string]interface{}, 6)
+	}
+
+	data["@timestamp"] = entry.Time.Format(timestampFormat)
+	data["log.level"] = entry.Level.String()
+	data["message"] = entry.Message
+	data["ecs.version"] = ECSVersion
+	if ecsError != nil {
+		data["error"] = ecsError
+	}
+	if traceID != nil {
+		data["trace.id"] = traceID
+	}
+	if spanID != nil {
+		data["span.id"] = spanID
+	}
+	if entry.Caller != nil {
+		data["log.origin"] = map[string]interface{}{
+			"file.name": entry.Caller.File,
+			"file.line": entry.Caller.Line,
+			"function":  entry.Caller.Function,
+		}
+	}
+
+	serialized, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal fields to JSON, %v", err)
+	}
+	return append(serialized, '\n'), nil
+}
+
+// ecsErrorFields builds the ECS `error` object. The stack trace is taken from
+// the `%+v` verb, which errors carrying a stack trace (such as those created
+// by github.com/pkg/errors) implement to print it.
+func ecsErrorFields(v interface{}) map[string]interface{} {
+	err, ok := v.(error)
+	if !ok {
+		return map[string]interface{}{"message": fmt.Sprint(v)}
+	}
+
+	fields := map[string]interface{}{
+		"message": err.Error(),
+		"type":    fmt.Sprintf("%T", err),
+	}
+	if verbose := fmt.Sprintf("%+v", err); verbose != err.Error() {
+		fields["stack_trace"] = verbose
+	}
+	return fields
+}
@@ -0,0 +1,149 @@
+package logrus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func formatECS(t *testing.T, f *ECSFormatter, entry *Entry) map[string]interface{} {
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal("Unable to unmarshal formatted entry: ", err)
+	}
+	return data
+}
+
+func TestECSFormatterBaseFields(t *testing.T) {
+	entry := WithField("animal", "walrus")
+	entry.Time = time.Date(2017, 5, 6, 8, 48, 33, 0, time.UTC)
+	entry.Level = WarnLevel
+	entry.Message = "A walrus appears"
+
+	data := formatECS(t, &ECSFormatter{}, entry)
+
+	assert.Equal(t, "2017-05-06T08:48:33.000Z", data["@timestamp"])
+	assert.Equal(t, "warning", data["log.level"])
+	assert.Equal(t, "A walrus appears", data["message"])
+	assert.Equal(t, ECSVersion, data["ecs.version"])
+	assert.Equal(t, map[string]interface{}{"animal": "walrus"}, data["fields"])
+	assert.NotContains(t, data, "error")
+	assert.NotContains(t, data, "log.origin")
+}
+
+func TestECSFormatterDataKey(t *testing.T) {
+	entry := WithFields(Fields{"http.status": 200, "http.method": "GET", "user": "walrus"})
+
+	data := formatECS(t, &ECSFormatter{DataKey: "labels"}, entry)
+	assert.Equal(t, map[string]interface{}{
+		"http": map[string]interface{}{"status": float64(200), "method": "GET"},
+		"user": "walrus",
+	}, data["labels"])
+
+	data = formatECS(t, &ECSFormatter{DataKey: "-", DisableKeyExpansion: true}, entry)
+	assert.Equal(t, float64(200), data["http.status"])
+	assert.Equal(t, "walrus", data["user"])
+	assert.Equal(t, ECSVersion, data["ecs.version"])
+}
+
+type stackError struct{}
+
+func (stackError) Error() string { return "wild walrus" }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	if s.Flag('+') {
+		fmt.Fprint(s, "wild walrus\nmain.main()\n\t/tmp/main.go:12")
+		return
+	}
+	fmt.Fprint(s, e.Error())
+}
+
+func TestECSFormatterError(t *testing.T) {
+	data := formatECS(t, &ECSFormatter{}, WithError(errors.New("wild walrus")))
+	assert.Equal(t, map[string]interface{}{
+		"message": "wild walrus",
+		"type":    "*errors.errorString",
+	}, data["error"])
+	assert.NotContains(t, data, "fields")
+
+	data = formatECS(t, &ECSFormatter{}, WithError(stackError{}))
+	assert.Equal(t, map[string]interface{}{
+		"message":     "wild walrus",
+		"type":        "logrus.stackError",
+		"stack_trace": "wild walrus\nmain.main()\n\t/tmp/main.go:12",
+	}, data["error"])
+
+	data = formatECS(t, &ECSFormatter{}, WithField("omg", errors.New("wild walrus")))
+	assert.Equal(t, map[string]interface{}{"omg": "wild walrus"}, data["fields"])
+}
+
+func TestECSFormatterCaller(t *testing.T) {
+	entry := WithField("animal", "walrus")
+	entry.Caller = &runtime.Frame{File: "/src/main.go", Line: 42, Function: "main.main"}
+
+	data := formatECS(t, &ECSFormatter{}, entry)
+	assert.Equal(t, map[string]interface{}{
+		"file.name": "/src/main.go",
+		"file.line": float64(42),
+		"function":  "main.main",
+	}, data["log.origin"])
+}
+
+func TestECSFormatterTrace(t *testing.T) {
+	entry := WithFields(Fields{
+		FieldKeyTraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		FieldKeySpanID:     "00f067aa0ba902b7",
+		FieldKeyTraceFlags: "01",
+	})
+
+	data := formatECS(t, &ECSFormatter{}, entry)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", data["trace.id"])
+	assert.Equal(t, "00f067aa0ba902b7", data["span.id"])
+	assert.Equal(t, map[string]interface{}{"trace_flags": "01"}, data["fields"])
+}
+
+func TestECSFormatterTraceFieldMap(t *testing.T) {
+	fieldMap := FieldMap{FieldKeyTraceID: "tid", FieldKeySpanID: "sid"}
+	tc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	entry := New().WithContext(ContextWithTrace(context.Background(), tc))
+	assert.NoError(t, (&TraceHook{FieldMap: fieldMap, DisableTraceFlags: true}).Fire(entry))
+
+	data := formatECS(t, &ECSFormatter{TraceFieldMap: fieldMap}, entry)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", data["trace.id"])
+	assert.Equal(t, "00f067aa0ba902b7", data["span.id"])
+	assert.Nil(t, data["fields"])
+}
+
+func TestExpandDottedKeys(t *testing.T) {
+	nested := map[string]interface{}{"b": 1}
+	expanded := expandDottedKeys(map[string]interface{}{
+		"a.b.c": 1,
+		"a.b.d": 2,
+		"x":     "y",
+		"x.z":   3,
+		"m":     nested,
+		"m.c":   4,
+		".bad":  5,
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"a":    map[string]interface{}{"b": map[string]interface{}{"c": 1, "d": 2}},
+		"x":    "y",
+		"x.z":  3,
+		"m":    nested,
+		"m.c":  4,
+		".bad": 5,
+	}, expanded)
+	assert.Equal(t, map[string]interface{}{"b": 1}, nested)
+}
@@ -2,13 +2,27 @@ package logrus
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
 
-var bufferPool *sync.Pool
+var (
+	bufferPool *sync.Pool
+
+	// qualified package name, cached at first use
+	logrusPackage string
+
+	// Used for caller information initialisation
+	callerInitOnce sync.Once
+)
+
+// Maximum number of stack frames inspected when looking for the caller.
+const maximumCallerDepth = 25
 
 func init() {
 	bufferPool = &sync.Pool{
@@ -40,8 +54,15 @@ type Entry struct {
 	// Message passed to Debug, Info, Warn, Error, Fatal or Panic
 	Message string
 
+	// Calling method, with package name. Only set when the logger has
+	// ReportCaller enabled.
+	Caller *runtime.Frame
+
 	// When formatter is called in entry.log(), an Buffer may be set to entry
 	Buffer *bytes.Buffer
+
+	// Contains the context set by the user. Useful for hook processing etc.
+	Context context.Context
 }
 
 func NewEntry(logger *Logger) *Entry {
@@ -68,6 +89,15 @@ func (entry *Entry) WithError(err error) *Entry {
 	return entry.WithField(ErrorKey, err)
 }
 
+// Add a context to the Entry.
+func (entry *Entry) WithContext(ctx context.Context) *Entry {
+	data := make(Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	return &Entry{Logger: entry.Logger, Data: data, Context: ctx}
+}
+
 // Add a single field to the Entry.
 func (entry *Entry) WithField(key string, value interface{}) *Entry {
 	return entry.WithFields(Fields{key: value})
@@ -82,16 +112,61 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 	for k, v := range fields {
 		data[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: data}
+	return &Entry{Logger: entry.Logger, Data: data, Context: entry.Context}
+}
+
+// getPackageName reduces a fully qualified function name to the package name.
+func getPackageName(f string) string {
+	for {
+		lastPeriod := strings.LastIndex(f, ".")
+		lastSlash := strings.LastIndex(f, "/")
+		if lastPeriod > lastSlash {
+			f = f[:lastPeriod]
+		} else {
+			break
+		}
+	}
+
+	return f
+}
+
+// getCaller retrieves the name of the first non-logrus calling function.
+func getCaller() *runtime.Frame {
+	callerInitOnce.Do(func() {
+		pcs := make([]uintptr, 2)
+		_ = runtime.Callers(0, pcs)
+		logrusPackage = getPackageName(runtime.FuncForPC(pcs[1]).Name())
+	})
+
+	pcs := make([]uintptr, maximumCallerDepth)
+	depth := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:depth])
+
+	for {
+		f, more := frames.Next()
+		if getPackageName(f.Function) != logrusPackage {
+			return &f
+		}
+		if !more {
+			break
+		}
+	}
+
+	// if we got here, we failed to find the caller's context
+	return nil
 }
 
 // This function is not declared with a pointer value because otherwise
 // race conditions will occur when using multiple goroutines
 func (entry Entry) log(level Level, msg string) {
 	var buffer *bytes.Buffer
-	entry.Time = time.Now()
+	clock, reportCaller := entry.Logger.settings()
+	entry.Time = clock.Now()
 	entry.Level = level
 	entry.Message = msg
+	if reportCaller {
+		entry.Caller = getCaller()
+	}
 
 	if err := entry.Logger.Hooks.Fire(level, &entry); err != nil {
 		entry.Logger.mu.Lock()
@@ -161,7 +236,7 @@ func (entry *Entry) Fatal(args ...interface{}) {
 	if entry.Logger.level() >= FatalLevel {
 		entry.log(FatalLevel, fmt.Sprint(args...))
 	}
-	Exit(1)
+	entry.Logger.exit(1)
 }
 
 func (entry *Entry) Panic(args ...interface{}) {
@@ -207,9 +282,9 @@ func (entry *Entry) Errorf(format string, args ...interface{}) {
 
 func (entry *Entry) Fatalf(format string, args ...interface{}) {
 	if entry.Logger.level() >= FatalLevel {
-		entry.Fatal(fmt.Sprintf(format, args...))
+		entry.log(FatalLevel, fmt.Sprintf(format, args...))
 	}
-	Exit(1)
+	entry.Logger.exit(1)
 }
 
 func (entry *Entry) Panicf(format string, args ...interface{}) {
@@ -254,9 +329,9 @@ func (entry *Entry) Errorln(args ...interface{}) {
 
 func (entry *Entry) Fatalln(args ...interface{}) {
 	if entry.Logger.level() >= FatalLevel {
-		entry.Fatal(entry.sprintlnn(args...))
+		entry.log(FatalLevel, entry.sprintlnn(args...))
 	}
-	Exit(1)
+	entry.Logger.exit(1)
 }
 
 func (entry *Entry) Panicln(args ...interface{}) {
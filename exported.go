@@ -1,6 +1,7 @@
 package logrus
 
 import (
+	"context"
 	"io"
 )
 
@@ -41,6 +42,17 @@ func GetLevel() Level {
 	return std.level()
 }
 
+// SetReportCaller sets whether the standard logger will include the calling
+// method as a field.
+func SetReportCaller(include bool) {
+	std.SetReportCaller(include)
+}
+
+// SetClock sets the clock telling the time of entries of the standard logger.
+func SetClock(clock Clock) {
+	std.SetClock(clock)
+}
+
 // AddHook adds a hook to the standard logger hooks.
 func AddHook(hook Hook) {
 	std.mu.Lock()
@@ -53,6 +65,11 @@ func WithError(err error) *Entry {
 	return std.WithField(ErrorKey, err)
 }
 
+// WithContext creates an entry from the standard logger and adds a context to it.
+func WithContext(ctx context.Context) *Entry {
+	return std.WithContext(ctx)
+}
+
 // WithField creates an entry from the standard logger and adds a field to
 // it. If you want multiple fields, use `WithFields`.
 //
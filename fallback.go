@@ -0,0 +1,334 @@
+package logrus
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FallbackWriter writes to Primary and, when that fails, keeps the entries
+// in a spool file to replay them to Primary once it recovers. It's meant to
+// be used as the `Out` of a logger whose output may go away for a while, such
+// as a file on a network volume:
+//
+//  log.Out = &logrus.FallbackWriter{
+//    Primary: file,
+//    SpoolPath: "/var/tmp/app.spool",
+//  }
+//
+// After a failure, Primary is tried again on the first write after
+// RetryInterval, and the spooled entries are replayed before that write.
+// While entries are spooled, the replay is also tried in the background every
+// RetryInterval, so they don't wait for the next write. Entries which can't
+// be spooled are written to Fallback. Write only fails when all of them fail.
+// Call Close to stop the background retries.
+type FallbackWriter struct {
+	Primary io.Writer
+
+	// SpoolPath is the file entries are kept in while Primary is failing.
+	// Without it, those entries are written to Fallback and not replayed.
+	SpoolPath string
+	// MaxSpoolSize is the size in bytes beyond which entries are no longer
+	// spooled but written to Fallback. Zero means no limit.
+	MaxSpoolSize int64
+
+	// Fallback receives the entries which can't be spooled. Defaults to
+	// os.Stderr.
+	Fallback io.Writer
+
+	// RetryInterval is how long to wait after a failure of Primary before
+	// trying it again. Defaults to five seconds.
+	RetryInterval time.Duration
+
+	// OnError, when set, is called with every write error, Primary's
+	// included.
+	OnError func(err error)
+
+	mu        sync.Mutex
+	failing   bool
+	failedAt  time.Time
+	spool     *os.File
+	spoolSize int64
+	replayed  int64
+	// checked is set once the spool file left by a previous process, if
+	// any, was looked for.
+	checked bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// fallbackReplayChunk is the size beyond which the spooled entries are
+// written to Primary in several writes, so a large spool file is never read
+// in full.
+const fallbackReplayChunk = 64 << 10
+
+func (w *FallbackWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.failing || time.Since(w.failedAt) >= w.retryInterval() {
+		err := w.replay()
+		if err == nil {
+			if _, err = w.Primary.Write(p); err == nil {
+				w.failing = false
+				return len(p), nil
+			}
+		}
+		w.reportError(err)
+		w.failing = true
+		w.failedAt = time.Now()
+	}
+
+	if err := w.spoolWrite(p); err == nil {
+		w.startRetrying()
+		return len(p), nil
+	} else if w.SpoolPath != "" {
+		w.reportError(err)
+	}
+
+	fallback := w.Fallback
+	if fallback == nil {
+		fallback = os.Stderr
+	}
+	n, err := fallback.Write(p)
+	if err != nil {
+		w.reportError(err)
+	}
+	return n, err
+}
+
+// Failing reports whether Primary failed on the last attempt to write to
+// it.
+func (w *FallbackWriter) Failing() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.failing
+}
+
+// Close stops the background retries and closes the spool file, leaving the
+// entries it holds to be replayed by the next FallbackWriter using it.
+func (w *FallbackWriter) Close() error {
+	w.mu.Lock()
+	stopped := w.stopped
+	if w.stop != nil {
+		close(w.stop)
+		w.stop, w.stopped = nil, nil
+	}
+	w.mu.Unlock()
+	if stopped != nil {
+		<-stopped
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.spool == nil {
+		return nil
+	}
+	err := w.spool.Close()
+	w.spool, w.checked = nil, false
+	return err
+}
+
+func (w *FallbackWriter) retryInterval() time.Duration {
+	if w.RetryInterval <= 0 {
+		return 5 * time.Second
+	}
+	return w.RetryInterval
+}
+
+// startRetrying starts the background retries, unless they are running.
+func (w *FallbackWriter) startRetrying() {
+	if w.stop != nil {
+		return
+	}
+	w.stop, w.stopped = make(chan struct{}), make(chan struct{})
+	go w.retry(w.stop, w.stopped)
+}
+
+// retry replays the spooled entries every RetryInterval, until it succeeds
+// or stop is closed.
+func (w *FallbackWriter) retry(stop, stopped chan struct{}) {
+	defer close(stopped)
+
+	interval := w.retryInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		w.mu.Lock()
+		select {
+		case <-stop:
+			w.mu.Unlock()
+			return
+		default:
+		}
+		if w.failing && time.Since(w.failedAt) >= interval {
+			if err := w.replay(); err != nil {
+				w.reportError(err)
+				w.failedAt = time.Now()
+			} else {
+				w.failing = false
+			}
+		}
+		if !w.failing {
+			w.stop, w.stopped = nil, nil
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *FallbackWriter) reportError(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+func (w *FallbackWriter) openSpool() error {
+	if w.spool != nil {
+		return nil
+	}
+	f, err := os.OpenFile(w.SpoolPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.spool, w.spoolSize = f, info.Size()
+	return nil
+}
+
+func (w *FallbackWriter) spoolWrite(p []byte) error {
+	if w.SpoolPath == "" {
+		return errors.New("no spool file")
+	}
+	if err := w.openSpool(); err != nil {
+		return err
+	}
+	if w.MaxSpoolSize > 0 && w.spoolSize+int64(len(p)) > w.MaxSpoolSize {
+		return fmt.Errorf("spool file %s is full", w.SpoolPath)
+	}
+	n, err := w.spool.Write(p)
+	w.spoolSize += int64(n)
+	return err
+}
+
+// replay writes the spooled entries to Primary in chunks of whole lines, and
+// empties the spool file once they are all written. A failed replay resumes
+// at the chunk which failed, so Primary may receive its entries twice.
+func (w *FallbackWriter) replay() error {
+	if w.SpoolPath == "" {
+		return nil
+	}
+	if w.spool == nil {
+		// Entries may have been left by a previous process, which is only
+		// checked on the first write: this one spools through w.spool.
+		if w.checked {
+			return nil
+		}
+		w.checked = true
+		if info, err := os.Stat(w.SpoolPath); err != nil || info.Size() == 0 {
+			return nil
+		}
+	}
+	if err := w.openSpool(); err != nil {
+		return err
+	}
+	if w.spoolSize == 0 {
+		return nil
+	}
+
+	if _, err := w.spool.Seek(w.replayed, 0); err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.LimitReader(w.spool, w.spoolSize-w.replayed))
+	var chunk []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		chunk = append(chunk, line...)
+		if len(chunk) > 0 && (len(chunk) >= fallbackReplayChunk || err == io.EOF) {
+			if _, err := w.Primary.Write(chunk); err != nil {
+				return err
+			}
+			w.replayed += int64(len(chunk))
+			chunk = chunk[:0]
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := w.spool.Truncate(0); err != nil {
+		return err
+	}
+	w.spoolSize, w.replayed = 0, 0
+	return nil
+}
+
+// FallbackHook wraps a hook, and writes the entries it fails to fire to
+// Writer instead of reporting the error. Wrap a hook shipping entries over
+// the network with it to keep the entries the hook loses:
+//
+//  log.AddHook(&logrus.FallbackHook{Hook: hook, Writer: spool})
+type FallbackHook struct {
+	Hook Hook
+	// Writer receives the entries the hook failed to fire. Defaults to
+	// os.Stderr. A FallbackWriter may be used for spooling.
+	Writer io.Writer
+	// Formatter formats the entries written to Writer. Defaults to
+	// JSONFormatter.
+	Formatter Formatter
+}
+
+func (hook *FallbackHook) Levels() []Level {
+	return hook.Hook.Levels()
+}
+
+// Flush flushes the wrapped hook, if it implements Flusher.
+func (hook *FallbackHook) Flush() error {
+	if flusher, ok := hook.Hook.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Fire fires the wrapped hook and, if it fails, writes the entry to Writer.
+// It only returns an error if writing the entry fails as well.
+func (hook *FallbackHook) Fire(entry *Entry) error {
+	hookErr := hook.Hook.Fire(entry)
+	if hookErr == nil {
+		return nil
+	}
+
+	formatter := hook.Formatter
+	if formatter == nil {
+		formatter = &JSONFormatter{}
+	}
+	serialized, err := formatter.Format(entry)
+	if err != nil {
+		return fmt.Errorf("%v, and failed to format the entry, %v", hookErr, err)
+	}
+	writer := hook.Writer
+	if writer == nil {
+		writer = os.Stderr
+	}
+	if _, err := writer.Write(serialized); err != nil {
+		return fmt.Errorf("%v, and failed to write the entry, %v", hookErr, err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package logrus
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyWriter fails while down is set, and on its failOn-th write if set. It
+// records the size of the successful writes.
+type flakyWriter struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+	down   bool
+	failOn int
+	writes int
+	sizes  []int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.writes++; w.down || w.writes == w.failOn {
+		return 0, errors.New("volume went away")
+	}
+	w.sizes = append(w.sizes, len(p))
+	return w.buffer.Write(p)
+}
+
+func (w *flakyWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+func (w *flakyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.String()
+}
+
+func TestFallbackWriterSpoolsAndReplays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary := &flakyWriter{}
+	var errs []error
+	w := &FallbackWriter{
+		Primary:       primary,
+		SpoolPath:     filepath.Join(dir, "spool"),
+		RetryInterval: time.Hour,
+		// Called with w.mu held.
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	defer w.Close()
+
+	w.Write([]byte("one\n"))
+	primary.setDown(true)
+	n, err := w.Write([]byte("two\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.True(t, w.Failing())
+	w.Write([]byte("three\n"))
+	assert.Equal(t, "one\n", primary.String())
+	w.mu.Lock()
+	assert.Len(t, errs, 1)
+	w.mu.Unlock()
+
+	spooled, _ := ioutil.ReadFile(w.SpoolPath)
+	assert.Equal(t, "two\nthree\n", string(spooled))
+
+	primary.setDown(false)
+	w.mu.Lock()
+	w.failedAt = time.Now().Add(-time.Hour)
+	w.mu.Unlock()
+	w.Write([]byte("four\n"))
+	assert.Equal(t, "one\ntwo\nthree\nfour\n", primary.String())
+	assert.False(t, w.Failing())
+
+	spooled, _ = ioutil.ReadFile(w.SpoolPath)
+	assert.Empty(t, spooled)
+}
+
+func TestFallbackWriterRetryInterval(t *testing.T) {
+	primary := &flakyWriter{down: true}
+	var fallback bytes.Buffer
+	w := &FallbackWriter{Primary: primary, Fallback: &fallback, RetryInterval: time.Hour}
+
+	w.Write([]byte("one\n"))
+	primary.setDown(false)
+	w.Write([]byte("two\n"))
+	assert.Equal(t, "one\ntwo\n", fallback.String())
+	assert.Empty(t, primary.String())
+}
+
+func TestFallbackWriterRetriesInBackground(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary := &flakyWriter{down: true}
+	w := &FallbackWriter{
+		Primary:       primary,
+		SpoolPath:     filepath.Join(dir, "spool"),
+		RetryInterval: time.Millisecond,
+	}
+	defer w.Close()
+
+	w.Write([]byte("one\n"))
+	assert.True(t, w.Failing())
+	primary.setDown(false)
+
+	// Replayed without another write.
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Failing() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, w.Failing())
+	assert.Equal(t, "one\n", primary.String())
+	spooled, _ := ioutil.ReadFile(w.SpoolPath)
+	assert.Empty(t, spooled)
+}
+
+func TestFallbackWriterCloseStopsRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary := &flakyWriter{down: true}
+	w := &FallbackWriter{
+		Primary:       primary,
+		SpoolPath:     filepath.Join(dir, "spool"),
+		RetryInterval: time.Millisecond,
+	}
+	w.Write([]byte("one\n"))
+	w.mu.Lock()
+	stopped := w.stopped
+	w.mu.Unlock()
+	if stopped == nil {
+		t.Fatal("retries not started")
+	}
+	assert.NoError(t, w.Close())
+
+	select {
+	case <-stopped:
+	default:
+		t.Error("retries still running after Close")
+	}
+	primary.setDown(false)
+	assert.Empty(t, primary.String())
+	spooled, _ := ioutil.ReadFile(w.SpoolPath)
+	assert.Equal(t, "one\n", string(spooled))
+}
+
+func TestFallbackWriterReplaysInChunks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spool := filepath.Join(dir, "spool")
+	line := strings.Repeat("x", 99) + "\n"
+	left := strings.Repeat(line, 3*fallbackReplayChunk/len(line))
+	ioutil.WriteFile(spool, []byte(left), 0600)
+
+	// The second chunk fails, and is the only one written again.
+	primary := &flakyWriter{failOn: 2}
+	w := &FallbackWriter{Primary: primary, SpoolPath: spool, RetryInterval: time.Hour}
+	defer w.Close()
+
+	w.Write([]byte("new\n"))
+	assert.True(t, w.Failing())
+	w.mu.Lock()
+	w.failedAt = time.Now().Add(-time.Hour)
+	w.mu.Unlock()
+	w.Write([]byte("newer\n"))
+
+	assert.False(t, w.Failing())
+	assert.Equal(t, left+"new\nnewer\n", primary.String())
+	primary.mu.Lock()
+	defer primary.mu.Unlock()
+	assert.True(t, len(primary.sizes) > 3, "%v", primary.sizes)
+	for _, size := range primary.sizes {
+		assert.True(t, size <= fallbackReplayChunk+len(line), "%v", primary.sizes)
+	}
+}
+
+func TestFallbackWriterFullSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var fallback bytes.Buffer
+	w := &FallbackWriter{
+		Primary:      &flakyWriter{down: true},
+		SpoolPath:    filepath.Join(dir, "spool"),
+		MaxSpoolSize: 6,
+		Fallback:     &fallback,
+	}
+	defer w.Close()
+
+	w.Write([]byte("one\n"))
+	w.Write([]byte("two\n"))
+	spooled, _ := ioutil.ReadFile(w.SpoolPath)
+	assert.Equal(t, "one\n", string(spooled))
+	assert.Equal(t, "two\n", fallback.String())
+}
+
+func TestFallbackWriterReplaysPreviousSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spool := filepath.Join(dir, "spool")
+	ioutil.WriteFile(spool, []byte("left over\n"), 0600)
+
+	primary := &flakyWriter{}
+	w := &FallbackWriter{Primary: primary, SpoolPath: spool}
+	defer w.Close()
+
+	w.Write([]byte("new\n"))
+	assert.Equal(t, "left over\nnew\n", primary.String())
+
+	// Only looked for on the first write.
+	ioutil.WriteFile(spool, []byte("later\n"), 0600)
+	w.Write([]byte("newer\n"))
+	assert.Equal(t, "left over\nnew\nnewer\n", primary.String())
+}
+
+func TestFallbackWriterAsLoggerOutput(t *testing.T) {
+	primary := &flakyWriter{down: true}
+	var fallback bytes.Buffer
+	logger := New()
+	logger.Formatter = &TextFormatter{DisableColors: true, DisableTimestamp: true}
+	logger.Out = &FallbackWriter{Primary: primary, Fallback: &fallback}
+
+	logger.Info("A walrus appears")
+	assert.Equal(t, "level=info msg=\"A walrus appears\" \n", fallback.String())
+}
+
+type failingHook struct{}
+
+func (failingHook) Levels() []Level        { return AllLevels }
+func (failingHook) Fire(entry *Entry) error { return errors.New("unreachable") }
+
+func TestFallbackHook(t *testing.T) {
+	var fallback bytes.Buffer
+	logger := New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(&FallbackHook{
+		Hook:      failingHook{},
+		Writer:    &fallback,
+		Formatter: &TextFormatter{DisableColors: true, DisableTimestamp: true},
+	})
+
+	logger.WithField("animal", "walrus").Warn("A walrus appears")
+	assert.Equal(t, "level=warning msg=\"A walrus appears\" animal=walrus \n", fallback.String())
+
+	hook := &FallbackHook{Hook: failingHook{}, Writer: &flakyWriter{down: true}}
+	assert.EqualError(t, hook.Fire(NewEntry(logger)), "unreachable, and failed to write the entry, volume went away")
+}
@@ -1,6 +1,10 @@
 package logrus
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+)
 
 const DefaultTimestampFormat = time.RFC3339
 
@@ -50,3 +54,60 @@ func setField(data Fields, k string, v interface{}) {
 	}
 	data[k] = v
 }
+
+// expandDottedKeys turns dotted keys such as `http.status` into nested maps,
+// so `{"http.status": 200}` becomes `{"http": {"status": 200}}`. Keys are
+// processed in sorted order; a key whose path clashes with a value already in
+// place is kept verbatim rather than silently dropped. Maps passed in as
+// values are never modified.
+func expandDottedKeys(data map[string]interface{}) map[string]interface{} {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	expanded := make(dottedNode, len(data))
+	for _, k := range keys {
+		v := data[k]
+		parts := strings.Split(k, ".")
+		node := expanded
+		for _, part := range parts[:len(parts)-1] {
+			if part == "" {
+				node = nil
+				break
+			}
+			child, ok := node[part]
+			if !ok {
+				child = make(dottedNode)
+				node[part] = child
+			}
+			if node, ok = child.(dottedNode); !ok {
+				break
+			}
+		}
+		last := parts[len(parts)-1]
+		if _, taken := node[last]; node == nil || taken || last == "" {
+			expanded[k] = v
+			continue
+		}
+		node[last] = v
+	}
+
+	return expanded.toMap()
+}
+
+// dottedNode marks the objects created by expandDottedKeys, so user supplied
+// maps are never descended into.
+type dottedNode map[string]interface{}
+
+func (n dottedNode) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(n))
+	for k, v := range n {
+		if child, ok := v.(dottedNode); ok {
+			v = child.toMap()
+		}
+		m[k] = v
+	}
+	return m
+}
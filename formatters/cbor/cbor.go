@@ -0,0 +1,584 @@
+// Package logrus_cbor encodes logrus entries as CBOR maps, and decodes them
+// back. See https://tools.ietf.org/html/rfc7049 for the format.
+package logrus_cbor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Major types of the initial byte of each data item.
+const (
+	majorUint   = 0 << 5
+	majorNegInt = 1 << 5
+	majorBytes  = 2 << 5
+	majorText   = 3 << 5
+	majorArray  = 4 << 5
+	majorMap    = 5 << 5
+	majorTag    = 6 << 5
+	majorSimple = 7 << 5
+)
+
+// Tag numbers time.Time values are decoded from. They are always encoded
+// as TagEpochTime.
+const (
+	TagDateTime  = 0
+	TagEpochTime = 1
+)
+
+// Tag is a CBOR tagged data item, whose content is given a meaning by the
+// tag number. Tags other than the time tags are decoded as a Tag.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// Marshal returns the CBOR encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append appends the CBOR encoding of v to b. Maps are encoded with their
+// keys sorted, time.Time as an epoch based date/time and errors as their
+// message. Values of other types, such as structs, are encoded as their JSON
+// representation would be.
+func Append(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xf6), nil
+	case bool:
+		if v {
+			return append(b, 0xf5), nil
+		}
+		return append(b, 0xf4), nil
+	case string:
+		return appendString(b, majorText, v), nil
+	case []byte:
+		return appendString(b, majorBytes, string(v)), nil
+	case time.Time:
+		return appendTime(b, v), nil
+	case Tag:
+		return Append(appendHead(b, majorTag, v.Number), v.Content)
+	case error:
+		return appendString(b, majorText, v.Error()), nil
+	case map[string]interface{}:
+		return appendMap(b, v)
+	case []interface{}:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			var err error
+			if b, err = Append(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendHead(b, majorUint, rv.Uint()), nil
+	case reflect.Float32:
+		b = append(b, majorSimple|26)
+		return appendUint32(b, math.Float32bits(float32(rv.Float()))), nil
+	case reflect.Float64:
+		b = append(b, majorSimple|27)
+		return appendUint64(b, math.Float64bits(rv.Float())), nil
+	case reflect.String:
+		return appendString(b, majorText, rv.String()), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String && !isJSONMarshaler(v) {
+			m := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				m[key.String()] = rv.MapIndex(key).Interface()
+			}
+			return appendMap(b, m)
+		}
+	case reflect.Slice, reflect.Array:
+		if !isJSONMarshaler(v) {
+			b = AppendArrayHeader(b, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				var err error
+				if b, err = Append(b, rv.Index(i).Interface()); err != nil {
+					return nil, err
+				}
+			}
+			return b, nil
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return append(b, 0xf6), nil
+		}
+	}
+
+	// Fall back to the JSON representation, decoded into generic values.
+	serialized, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: can't encode %T, %v", v, err)
+	}
+	var generic interface{}
+	d := json.NewDecoder(bytes.NewReader(serialized))
+	d.UseNumber()
+	if err := d.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("cbor: can't encode %T, %v", v, err)
+	}
+	return Append(b, fromJSON(generic))
+}
+
+func isJSONMarshaler(v interface{}) bool {
+	_, ok := v.(json.Marshaler)
+	return ok
+}
+
+// fromJSON converts json.Number values to integers where possible.
+func fromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = fromJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSON(item)
+		}
+	}
+	return v
+}
+
+func appendMap(b []byte, m map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = AppendMapHeader(b, len(m))
+	for _, k := range keys {
+		b = appendString(b, majorText, k)
+		var err error
+		if b, err = Append(b, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// AppendMapHeader appends the header of a map with n key/value pairs, to be
+// followed by the encoding of the keys and values.
+func AppendMapHeader(b []byte, n int) []byte {
+	return appendHead(b, majorMap, uint64(n))
+}
+
+// AppendArrayHeader appends the header of an array with n items, to be
+// followed by the encoding of the items.
+func AppendArrayHeader(b []byte, n int) []byte {
+	return appendHead(b, majorArray, uint64(n))
+}
+
+// appendHead appends the initial byte of a data item of the given major
+// type, followed by its argument in the shortest form.
+func appendHead(b []byte, major byte, u uint64) []byte {
+	switch {
+	case u < 24:
+		return append(b, major|byte(u))
+	case u <= math.MaxUint8:
+		return append(b, major|24, byte(u))
+	case u <= math.MaxUint16:
+		return append(b, major|25, byte(u>>8), byte(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, major|26), uint32(u))
+	}
+	return appendUint64(append(b, major|27), u)
+}
+
+func appendString(b []byte, major byte, s string) []byte {
+	return append(appendHead(b, major, uint64(len(s))), s...)
+}
+
+func appendInt(b []byte, i int64) []byte {
+	if i < 0 {
+		return appendHead(b, majorNegInt, uint64(-1-i))
+	}
+	return appendHead(b, majorUint, uint64(i))
+}
+
+// appendTime encodes whole seconds as an integer, and other times as a
+// float, which keeps microsecond precision for current dates.
+func appendTime(b []byte, t time.Time) []byte {
+	b = appendHead(b, majorTag, TagEpochTime)
+	if t.Nanosecond() == 0 {
+		return appendInt(b, t.Unix())
+	}
+	f := float64(t.Unix()) + float64(t.Nanosecond())/1e9
+	return appendUint64(append(b, majorSimple|27), math.Float64bits(f))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
+
+// A Decoder reads CBOR data items from an input stream.
+type Decoder struct {
+	r      *bufio.Reader
+	framed bool
+	depth  int
+}
+
+// NewDecoder returns a decoder reading consecutive CBOR data items from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// NewFramedDecoder returns a decoder reading data items each preceded by its
+// length, as written by a Formatter with LengthPrefix set.
+func NewFramedDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), framed: true}
+}
+
+// errBreak is returned by decodeNext for the "break" stop code ending
+// indefinite length items.
+var errBreak = errors.New("cbor: unexpected break")
+
+// Decode reads the next data item. Maps are returned as
+// map[string]interface{}, arrays as []interface{}, integers as int64 or,
+// when too large, uint64, floats as float64, byte strings as []byte, date/time
+// tags as time.Time and other tags as Tag. Both definite and indefinite
+// length items are supported. It returns io.EOF when the input ends between
+// data items.
+func (d *Decoder) Decode() (interface{}, error) {
+	if !d.framed {
+		return d.decode()
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxLength {
+		return nil, fmt.Errorf("cbor: frame length %d out of range", n)
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	inner := NewDecoder(bytes.NewReader(frame))
+	v, err := inner.decode()
+	if err == nil {
+		// The item must end the frame
+		if _, rerr := inner.r.ReadByte(); rerr != io.EOF {
+			err = errors.New("cbor: trailing data in frame")
+		}
+	}
+	return v, unexpectedEOF(err)
+}
+
+func (d *Decoder) decode() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.decodeValue(c)
+	return v, unexpectedEOF(err)
+}
+
+func (d *Decoder) decodeNext() (interface{}, error) {
+	if d.depth >= maxDepth {
+		return nil, fmt.Errorf("cbor: nesting deeper than %d", maxDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeValue(c)
+}
+
+func (d *Decoder) decodeValue(c byte) (interface{}, error) {
+	major, info := c&0xe0, c&0x1f
+	if c == 0xff {
+		return nil, errBreak
+	}
+	if major == majorSimple {
+		return d.decodeSimple(info)
+	}
+
+	if info == 31 {
+		switch major {
+		case majorBytes, majorText:
+			return d.readChunks(major)
+		case majorArray:
+			return d.readArray(-1)
+		case majorMap:
+			return d.readMap(-1)
+		}
+		return nil, fmt.Errorf("cbor: invalid initial byte 0x%02x", c)
+	}
+	u, err := d.readArgument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case majorNegInt:
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: integer -1-%d out of range", u)
+		}
+		return -1 - int64(u), nil
+	case majorBytes:
+		return d.readBytes(u)
+	case majorText:
+		b, err := d.readBytes(u)
+		return string(b), err
+	case majorArray:
+		if u > maxLength {
+			return nil, fmt.Errorf("cbor: length %d out of range", u)
+		}
+		return d.readArray(int(u))
+	case majorMap:
+		if u > maxLength {
+			return nil, fmt.Errorf("cbor: length %d out of range", u)
+		}
+		return d.readMap(int(u))
+	}
+	return d.readTag(u)
+}
+
+func (d *Decoder) readArgument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, fmt.Errorf("cbor: invalid additional information %d", info)
+	}
+	return d.readUint(1 << (info - 24))
+}
+
+func (d *Decoder) decodeSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		// null and undefined
+		return nil, nil
+	case 24:
+		c, err := d.r.ReadByte()
+		return uint64(c), err
+	case 25:
+		u, err := d.readUint(2)
+		return float16(uint16(u)), err
+	case 26:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 27:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	}
+	if info < 20 {
+		return uint64(info), nil
+	}
+	return nil, fmt.Errorf("cbor: invalid simple value %d", info)
+}
+
+// float16 converts an IEEE 754 half precision float.
+func float16(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+func (d *Decoder) readTag(number uint64) (interface{}, error) {
+	content, err := d.decodeNext()
+	if err != nil {
+		return nil, err
+	}
+
+	switch number {
+	case TagDateTime:
+		if s, ok := content.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case TagEpochTime:
+		switch v := content.(type) {
+		case int64:
+			return time.Unix(v, 0), nil
+		case uint64:
+			return time.Unix(int64(v), 0), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(math.Floor(frac*1e9+0.5))), nil
+		}
+	default:
+		return Tag{Number: number, Content: content}, nil
+	}
+	return nil, fmt.Errorf("cbor: invalid content %T for tag %d", content, number)
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[:size]); err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range buf[:size] {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// maxLength bounds the lengths read from the input, so corrupt data can't
+// trigger huge allocations.
+const maxLength = 64 << 20
+
+// maxDepth bounds the nesting of arrays, maps and tags read from the input,
+// so corrupt data can't exhaust the stack.
+const maxDepth = 1000
+
+func (d *Decoder) readBytes(n uint64) ([]byte, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("cbor: length %d out of range", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+// readChunks reads an indefinite length string, made of definite length
+// chunks of the same major type.
+func (d *Decoder) readChunks(major byte) (interface{}, error) {
+	var b []byte
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xff {
+			break
+		}
+		if c&0xe0 != major || c&0x1f == 31 {
+			return nil, fmt.Errorf("cbor: invalid chunk 0x%02x in indefinite length string", c)
+		}
+		n, err := d.readArgument(c & 0x1f)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := d.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		if b = append(b, chunk...); len(b) > maxLength {
+			return nil, fmt.Errorf("cbor: length %d out of range", len(b))
+		}
+	}
+	if major == majorText {
+		return string(b), nil
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// readArray reads n items, or items up to a break when n is negative.
+func (d *Decoder) readArray(n int) ([]interface{}, error) {
+	a := make([]interface{}, 0, sizeHint(n))
+	for i := 0; n < 0 || i < n; i++ {
+		v, err := d.decodeNext()
+		if err == errBreak && n < 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// readMap reads n key/value pairs, or pairs up to a break when n is negative.
+func (d *Decoder) readMap(n int) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, sizeHint(n))
+	for i := 0; n < 0 || i < n; i++ {
+		k, err := d.decodeNext()
+		if err == errBreak && n < 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decodeNext()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok {
+			m[s] = v
+		} else {
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// sizeHint caps the capacity preallocated for n items, n being negative for
+// indefinite lengths.
+func sizeHint(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > 1024 {
+		return 1024
+	}
+	return n
+}
@@ -0,0 +1,189 @@
+package logrus_cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalVectors(t *testing.T) {
+	for _, tc := range []struct {
+		value   interface{}
+		encoded []byte
+	}{
+		{nil, []byte{0xf6}},
+		{true, []byte{0xf5}},
+		{false, []byte{0xf4}},
+		{10, []byte{0x0a}},
+		{24, []byte{0x18, 0x18}},
+		{1000, []byte{0x19, 0x03, 0xe8}},
+		{1000000, []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{-1, []byte{0x20}},
+		{-1000, []byte{0x39, 0x03, 0xe7}},
+		{uint64(math.MaxUint64), []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{1.1, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{"IETF", []byte{0x64, 'I', 'E', 'T', 'F'}},
+		{[]byte{1, 2, 3, 4}, []byte{0x44, 1, 2, 3, 4}},
+		{[]int{1, 2, 3}, []byte{0x83, 1, 2, 3}},
+		{map[string]int{"b": 2, "a": 1}, []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x02}},
+		{errors.New("x"), []byte{0x61, 'x'}},
+		{time.Unix(1363896240, 0), []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}},
+		{time.Unix(1363896240, 500000000), []byte{0xc1, 0xfb, 0x41, 0xd4, 0x52, 0xd9, 0xec, 0x20, 0x00, 0x00}},
+		{Tag{Number: 32, Content: "http://www.example.com"}, append([]byte{0xd8, 0x20, 0x76}, "http://www.example.com"...)},
+		{struct {
+			A int `json:"a"`
+		}{3}, []byte{0xa1, 0x61, 'a', 0x03}},
+	} {
+		b, err := Marshal(tc.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.encoded, b, "%#v", tc.value)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := string(bytes.Repeat([]byte("x"), 70000))
+	values := []interface{}{
+		nil, true, int64(-5), int64(1 << 40), int64(math.MinInt64), uint64(math.MaxUint64), 2.25, "walrus", long,
+		[]byte("binary"),
+		[]interface{}{int64(1), "two", []interface{}{}},
+		map[string]interface{}{"nested": map[string]interface{}{"a": int64(1)}},
+		Tag{Number: 32, Content: "http://www.example.com"},
+	}
+
+	var b []byte
+	for _, v := range values {
+		var err error
+		b, err = Append(b, v)
+		assert.NoError(t, err)
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	for _, v := range values {
+		decoded, err := d.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, v, decoded)
+	}
+	_, err := d.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecodeVectors(t *testing.T) {
+	for _, tc := range []struct {
+		encoded []byte
+		value   interface{}
+	}{
+		// Half precision floats.
+		{[]byte{0xf9, 0x3c, 0x00}, 1.0},
+		{[]byte{0xf9, 0xc4, 0x00}, -4.0},
+		{[]byte{0xf9, 0x00, 0x01}, 5.960464477539063e-8},
+		{[]byte{0xf9, 0x7c, 0x00}, math.Inf(1)},
+		{[]byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, 100000.0},
+		// Indefinite length items.
+		{[]byte{0x5f, 0x42, 0x01, 0x02, 0x43, 0x03, 0x04, 0x05, 0xff}, []byte{1, 2, 3, 4, 5}},
+		{[]byte{0x7f, 0x65, 's', 't', 'r', 'e', 'a', 0x64, 'm', 'i', 'n', 'g', 0xff}, "streaming"},
+		{[]byte{0x9f, 0x01, 0x82, 0x02, 0x03, 0x9f, 0x04, 0x05, 0xff, 0xff}, []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{[]byte{0xbf, 0x61, 'a', 0x01, 0x61, 'b', 0x9f, 0x02, 0x03, 0xff, 0xff}, map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		// Date/time tags.
+		{append([]byte{0xc0, 0x74}, "2013-03-21T20:04:00Z"...), time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+	} {
+		decoded, err := NewDecoder(bytes.NewReader(tc.encoded)).Decode()
+		assert.NoError(t, err)
+		if ts, ok := tc.value.(time.Time); ok {
+			assert.True(t, ts.Equal(decoded.(time.Time)), "%v != %v", ts, decoded)
+			continue
+		}
+		assert.Equal(t, tc.value, decoded, "% x", tc.encoded)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Unix(1500000000, 0),
+		time.Unix(1500000000, 123456000),
+		time.Unix(-1, 0),
+	} {
+		b, err := Marshal(ts)
+		assert.NoError(t, err)
+		decoded, err := NewDecoder(bytes.NewReader(b)).Decode()
+		assert.NoError(t, err)
+		assert.WithinDuration(t, ts, decoded.(time.Time), time.Microsecond)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	b, _ := Marshal(map[string]interface{}{"a": "walrus"})
+	_, err := NewDecoder(bytes.NewReader(b[:len(b)-2])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0x9f, 0x01})).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0xff})).Decode()
+	assert.Error(t, err)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0x1c})).Decode()
+	assert.Error(t, err)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0x5f, 0x61, 'a', 0xff})).Decode()
+	assert.Error(t, err)
+}
+
+func TestFormatterRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &Formatter{LengthPrefix: true}
+
+	logger.WithFields(logrus.Fields{"animal": "walrus", "size": 10, "level": "high"}).Warn("A walrus appears")
+	logger.WithField("blob", []byte{0, 1}).Error("Binary")
+
+	d := NewFramedDecoder(&buffer)
+	record, err := d.DecodeRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.WarnLevel, record.Level)
+	assert.Equal(t, "A walrus appears", record.Message)
+	assert.WithinDuration(t, time.Now(), record.Time, time.Minute)
+	assert.Equal(t, logrus.Fields{"animal": "walrus", "size": int64(10), "fields.level": "high"}, record.Data)
+
+	record, err = d.DecodeRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.Fields{"blob": []byte{0, 1}}, record.Data)
+
+	_, err = d.DecodeRecord()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFramedDecoderTrailingData(t *testing.T) {
+	_, err := NewFramedDecoder(bytes.NewReader([]byte{0, 0, 0, 2, 0x01, 0x02})).Decode()
+	assert.Error(t, err)
+
+	_, err = NewFramedDecoder(bytes.NewReader([]byte{0, 0, 0, 2, 0x01})).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// A 5000 bytes string, larger than the read buffer, and a trailing byte
+	frame := append([]byte{0x79, 0x13, 0x88}, bytes.Repeat([]byte("a"), 5000)...)
+	frame = append(frame, 0x01)
+	framed := append([]byte{0, 0, byte(len(frame) >> 8), byte(len(frame))}, frame...)
+	_, err = NewFramedDecoder(bytes.NewReader(framed)).Decode()
+	assert.Error(t, err)
+}
+
+func TestDecodeLimits(t *testing.T) {
+	_, err := NewFramedDecoder(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff})).Decode()
+	assert.EqualError(t, err, "cbor: frame length 4294967295 out of range")
+
+	// Arrays each holding the next one
+	nested := bytes.Repeat([]byte{0x81}, maxDepth+1)
+	_, err = NewDecoder(bytes.NewReader(append(nested, 0x01))).Decode()
+	assert.EqualError(t, err, "cbor: nesting deeper than 1000")
+
+	v, err := NewDecoder(bytes.NewReader(append(nested[1:], 0x01))).Decode()
+	assert.NoError(t, err)
+	assert.IsType(t, []interface{}{}, v)
+}
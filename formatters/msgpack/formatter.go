@@ -0,0 +1,70 @@
+package logrus_msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/internal/record"
+)
+
+// Formatter encodes each entry as a MessagePack map holding the same keys as
+// the output of `logrus.JSONFormatter`: `time`, as a timestamp extension with
+// nanosecond precision, `level`, `msg` and the fields of the entry. User
+// fields clashing with those keys are prefixed with `fields.`.
+type Formatter struct {
+	// LengthPrefix precedes each entry with its length as a 4 byte big endian
+	// integer, so a stream of entries can be split without decoding it. Read
+	// such a stream back with NewFramedDecoder.
+	LengthPrefix bool
+}
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	var b []byte
+	if f.LengthPrefix {
+		b = make([]byte, 4, 64+16*len(entry.Data))
+	}
+
+	b, err := Append(b, NewRecordMap(entry))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal fields to MessagePack, %v", err)
+	}
+
+	if f.LengthPrefix {
+		binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	}
+	return b, nil
+}
+
+// NewRecordMap returns the map the Formatter encodes for an entry.
+func NewRecordMap(entry *logrus.Entry) map[string]interface{} {
+	return record.Map(entry)
+}
+
+// Record is an entry read back from the output of a Formatter.
+type Record struct {
+	Time    time.Time
+	Level   logrus.Level
+	Message string
+	Data    logrus.Fields
+}
+
+// DecodeRecord reads the next entry written by a Formatter.
+func (d *Decoder) DecodeRecord() (*Record, error) {
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: expected a map, got %T", v)
+	}
+
+	r := &Record{}
+	r.Time, r.Level, r.Message, r.Data, err = record.Split(m)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,563 @@
+// Package logrus_msgpack encodes logrus entries as MessagePack maps, and
+// decodes them back. See https://github.com/msgpack/msgpack/blob/master/spec.md
+// for the format.
+package logrus_msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Ext is a MessagePack extension value: application specific binary data
+// tagged with a type. Negative types are reserved by the specification.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// TimestampExt is the extension type time.Time values are encoded with.
+const TimestampExt = -1
+
+// Marshal returns the MessagePack encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append appends the MessagePack encoding of v to b. Maps are encoded with
+// their keys sorted, time.Time as a timestamp extension and errors as their
+// message. Values of other types, such as structs, are encoded as their JSON
+// representation would be.
+func Append(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return appendString(b, v), nil
+	case []byte:
+		return appendBinary(b, v), nil
+	case time.Time:
+		return appendTime(b, v), nil
+	case Ext:
+		return appendExt(b, v.Type, v.Data), nil
+	case error:
+		return appendString(b, v.Error()), nil
+	case map[string]interface{}:
+		return appendMap(b, v)
+	case []interface{}:
+		b = AppendArrayHeader(b, len(v))
+		for _, item := range v {
+			var err error
+			if b, err = Append(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(b, rv.Uint()), nil
+	case reflect.Float32:
+		b = append(b, 0xca)
+		return appendUint32(b, math.Float32bits(float32(rv.Float()))), nil
+	case reflect.Float64:
+		b = append(b, 0xcb)
+		return appendUint64(b, math.Float64bits(rv.Float())), nil
+	case reflect.String:
+		return appendString(b, rv.String()), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String && !isJSONMarshaler(v) {
+			m := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				m[key.String()] = rv.MapIndex(key).Interface()
+			}
+			return appendMap(b, m)
+		}
+	case reflect.Slice, reflect.Array:
+		if !isJSONMarshaler(v) {
+			b = AppendArrayHeader(b, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				var err error
+				if b, err = Append(b, rv.Index(i).Interface()); err != nil {
+					return nil, err
+				}
+			}
+			return b, nil
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return append(b, 0xc0), nil
+		}
+	}
+
+	// Fall back to the JSON representation, decoded into generic values.
+	serialized, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: can't encode %T, %v", v, err)
+	}
+	var generic interface{}
+	d := json.NewDecoder(bytes.NewReader(serialized))
+	d.UseNumber()
+	if err := d.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("msgpack: can't encode %T, %v", v, err)
+	}
+	return Append(b, fromJSON(generic))
+}
+
+func isJSONMarshaler(v interface{}) bool {
+	_, ok := v.(json.Marshaler)
+	return ok
+}
+
+// fromJSON converts json.Number values to integers where possible.
+func fromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = fromJSON(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromJSON(item)
+		}
+	}
+	return v
+}
+
+func appendMap(b []byte, m map[string]interface{}) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = AppendMapHeader(b, len(m))
+	for _, k := range keys {
+		b = appendString(b, k)
+		var err error
+		if b, err = Append(b, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// AppendMapHeader appends the header of a map with n key/value pairs, to be
+// followed by the encoding of the keys and values.
+func AppendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xde), uint16(n))
+	}
+	return appendUint32(append(b, 0xdf), uint32(n))
+}
+
+// AppendArrayHeader appends the header of an array with n items, to be
+// followed by the encoding of the items.
+func AppendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	}
+	return appendUint32(append(b, 0xdd), uint32(n))
+}
+
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendBinary(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+func appendExt(b []byte, typ int8, data []byte) []byte {
+	switch n := len(data); n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = appendUint16(append(b, 0xc8), uint16(n))
+		default:
+			b = appendUint32(append(b, 0xc9), uint32(n))
+		}
+	}
+	b = append(b, byte(typ))
+	return append(b, data...)
+}
+
+// appendTime uses the smallest of the three timestamp extension layouts able
+// to represent t.
+func appendTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint32(t.Nanosecond())
+	if sec>>34 == 0 {
+		if nsec == 0 && sec <= math.MaxUint32 {
+			return appendExt(b, TimestampExt, appendUint32(nil, uint32(sec)))
+		}
+		return appendExt(b, TimestampExt, appendUint64(nil, uint64(nsec)<<34|uint64(sec)))
+	}
+	return appendExt(b, TimestampExt, appendUint64(appendUint32(nil, nsec), uint64(sec)))
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(i))
+	}
+	return appendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(u))
+	}
+	return appendUint64(append(b, 0xcf), u)
+}
+
+func appendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
+
+// A Decoder reads MessagePack values from an input stream.
+type Decoder struct {
+	r      *bufio.Reader
+	framed bool
+	depth  int
+}
+
+// NewDecoder returns a decoder reading consecutive MessagePack values from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// NewFramedDecoder returns a decoder reading values each preceded by its
+// length, as written by a Formatter with LengthPrefix set.
+func NewFramedDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), framed: true}
+}
+
+// Decode reads the next value. Maps are returned as map[string]interface{},
+// arrays as []interface{}, integers as int64 or, when too large, uint64,
+// floats as float64, binary data as []byte, timestamps as time.Time and other
+// extensions as Ext. It returns io.EOF when the input ends between values.
+func (d *Decoder) Decode() (interface{}, error) {
+	if !d.framed {
+		return d.decode()
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: frame length %d out of range", n)
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	inner := NewDecoder(bytes.NewReader(frame))
+	v, err := inner.decode()
+	if err == nil {
+		// The item must end the frame
+		if _, rerr := inner.r.ReadByte(); rerr != io.EOF {
+			err = errors.New("msgpack: trailing data in frame")
+		}
+	}
+	return v, unexpectedEOF(err)
+}
+
+func (d *Decoder) decode() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.decodeValue(c)
+	return v, unexpectedEOF(err)
+}
+
+func (d *Decoder) decodeNext() (interface{}, error) {
+	if d.depth >= maxDepth {
+		return nil, fmt.Errorf("msgpack: nesting deeper than %d", maxDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeValue(c)
+}
+
+func (d *Decoder) decodeValue(c byte) (interface{}, error) {
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.readString(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.readArray(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.readMap(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (c - 0xcc))
+		if err != nil || u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), nil
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(int(n))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(int(n))
+	}
+
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[:size]); err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range buf[:size] {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// maxLength bounds the lengths read from the input, so corrupt data can't
+// trigger huge allocations.
+const maxLength = 64 << 20
+
+// maxDepth bounds the nesting of arrays and maps read from the input, so
+// corrupt data can't exhaust the stack.
+const maxDepth = 1000
+
+func (d *Decoder) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d out of range", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *Decoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *Decoder) readArray(n int) ([]interface{}, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d out of range", n)
+	}
+	a := make([]interface{}, 0, minInt(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.decodeNext()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *Decoder) readMap(n int) (map[string]interface{}, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("msgpack: length %d out of range", n)
+	}
+	m := make(map[string]interface{}, minInt(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.decodeNext()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decodeNext()
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok {
+			m[s] = v
+		} else {
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, nil
+}
+
+func (d *Decoder) readExt(n int) (interface{}, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != TimestampExt {
+		return Ext{Type: int8(typ), Data: data}, nil
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))), nil
+	}
+	return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
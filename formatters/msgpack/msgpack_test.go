@@ -0,0 +1,151 @@
+package logrus_msgpack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalVectors(t *testing.T) {
+	for _, tc := range []struct {
+		value   interface{}
+		encoded []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{false, []byte{0xc2}},
+		{1, []byte{0x01}},
+		{-1, []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{200, []byte{0xcc, 0xc8}},
+		{70000, []byte{0xce, 0x00, 0x01, 0x11, 0x70}},
+		{-70000, []byte{0xd2, 0xff, 0xfe, 0xee, 0x90}},
+		{uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{[]byte{1, 2}, []byte{0xc4, 0x02, 0x01, 0x02}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{errors.New("x"), []byte{0xa1, 'x'}},
+		{time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+		{Ext{Type: 0, Data: []byte{1, 2, 3}}, []byte{0xc7, 0x03, 0x00, 1, 2, 3}},
+		{struct {
+			A int `json:"a"`
+		}{3}, []byte{0x81, 0xa1, 'a', 0x03}},
+	} {
+		b, err := Marshal(tc.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.encoded, b, "%#v", tc.value)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := string(bytes.Repeat([]byte("x"), 70000))
+	values := []interface{}{
+		nil, true, int64(-5), int64(1 << 40), uint64(math.MaxUint64), 2.25, "walrus", long,
+		[]byte("binary"),
+		[]interface{}{int64(1), "two", []interface{}{}},
+		map[string]interface{}{"nested": map[string]interface{}{"a": int64(1)}},
+		Ext{Type: 5, Data: []byte{1, 2, 3, 4}},
+	}
+
+	var b []byte
+	for _, v := range values {
+		var err error
+		b, err = Append(b, v)
+		assert.NoError(t, err)
+	}
+
+	d := NewDecoder(bytes.NewReader(b))
+	for _, v := range values {
+		decoded, err := d.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, v, decoded)
+	}
+	_, err := d.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestTimestamps(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Unix(1500000000, 0),
+		time.Unix(1500000000, 123456789),
+		time.Unix(1<<35, 1),
+		time.Unix(-1, 5),
+	} {
+		b, err := Marshal(ts)
+		assert.NoError(t, err)
+		decoded, err := NewDecoder(bytes.NewReader(b)).Decode()
+		assert.NoError(t, err)
+		assert.True(t, ts.Equal(decoded.(time.Time)), "%v != %v", ts, decoded)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	b, _ := Marshal(map[string]interface{}{"a": "walrus"})
+	_, err := NewDecoder(bytes.NewReader(b[:len(b)-2])).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewDecoder(bytes.NewReader([]byte{0xc1})).Decode()
+	assert.Error(t, err)
+}
+
+func TestFormatterRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &Formatter{LengthPrefix: true}
+
+	logger.WithFields(logrus.Fields{"animal": "walrus", "size": 10, "level": "high"}).Warn("A walrus appears")
+	logger.WithField("blob", []byte{0, 1}).Error("Binary")
+
+	d := NewFramedDecoder(&buffer)
+	record, err := d.DecodeRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.WarnLevel, record.Level)
+	assert.Equal(t, "A walrus appears", record.Message)
+	assert.WithinDuration(t, time.Now(), record.Time, time.Minute)
+	assert.Equal(t, logrus.Fields{"animal": "walrus", "size": int64(10), "fields.level": "high"}, record.Data)
+
+	record, err = d.DecodeRecord()
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.Fields{"blob": []byte{0, 1}}, record.Data)
+
+	_, err = d.DecodeRecord()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFramedDecoderTrailingData(t *testing.T) {
+	_, err := NewFramedDecoder(bytes.NewReader([]byte{0, 0, 0, 2, 0x01, 0x02})).Decode()
+	assert.Error(t, err)
+
+	_, err = NewFramedDecoder(bytes.NewReader([]byte{0, 0, 0, 2, 0x01})).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// A 5000 bytes string, larger than the read buffer, and a trailing byte
+	frame := append([]byte{0xda, 0x13, 0x88}, bytes.Repeat([]byte("a"), 5000)...)
+	frame = append(frame, 0x01)
+	framed := append([]byte{0, 0, byte(len(frame) >> 8), byte(len(frame))}, frame...)
+	_, err = NewFramedDecoder(bytes.NewReader(framed)).Decode()
+	assert.Error(t, err)
+}
+
+func TestDecodeLimits(t *testing.T) {
+	_, err := NewFramedDecoder(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff})).Decode()
+	assert.EqualError(t, err, "msgpack: frame length 4294967295 out of range")
+
+	// Arrays each holding the next one
+	nested := bytes.Repeat([]byte{0x91}, maxDepth+1)
+	_, err = NewDecoder(bytes.NewReader(append(nested, 0x01))).Decode()
+	assert.EqualError(t, err, "msgpack: nesting deeper than 1000")
+
+	v, err := NewDecoder(bytes.NewReader(append(nested[1:], 0x01))).Decode()
+	assert.NoError(t, err)
+	assert.IsType(t, []interface{}{}, v)
+}
@@ -0,0 +1,201 @@
+package logrus_elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/http"
+)
+
+// DefaultURL is the address of an Elasticsearch node running with its
+// default configuration on the local host.
+const DefaultURL = "http://localhost:9200"
+
+// Config configures an Elasticsearch hook. The embedded HTTP hook
+// configuration sets the batching and retries; its URL is the base URL of the
+// cluster, defaulting to DefaultURL, and its Formatter, which formats the
+// documents, defaults to `logrus.ECSFormatter`. Its Encoder,
+// ResponseHandler and OnDrop are ignored.
+type Config struct {
+	logrus_http.Config
+
+	// Index is the prefix of the name of the indices the entries are written
+	// to, followed by their date formatted with IndexDateLayout. Default to
+	// "logs-" and "2006.01.02", for daily indices such as `logs-2017.07.14`.
+	// Set IndexDateLayout to "-" to write to a single index named Index.
+	Index           string
+	IndexDateLayout string
+
+	// DeadLetterPath is the file the entries Elasticsearch refuses, or that
+	// can't be delivered after all retries, are appended to. It's written in
+	// the bulk API format, so it can be replayed with
+	// `curl -H 'Content-Type: application/x-ndjson' --data-binary @file`.
+	DeadLetterPath string
+}
+
+// NewHook creates a hook indexing entries into the Elasticsearch cluster
+// described by config with the `_bulk` API. Entries the cluster fails to
+// index because it's overloaded are retried on their own, others are dropped
+// or appended to the dead-letter file.
+func NewHook(config Config) *logrus_http.Hook {
+	httpConfig := config.Config
+	if httpConfig.URL == "" {
+		httpConfig.URL = DefaultURL
+	}
+	httpConfig.URL = strings.TrimRight(httpConfig.URL, "/") + "/_bulk"
+	if httpConfig.Formatter == nil {
+		httpConfig.Formatter = &logrus.ECSFormatter{}
+	}
+
+	encoder := &Encoder{Index: config.Index, IndexDateLayout: config.IndexDateLayout}
+	if encoder.Index == "" {
+		encoder.Index = "logs-"
+	}
+	if encoder.IndexDateLayout == "" {
+		encoder.IndexDateLayout = "2006.01.02"
+	}
+	deadLetter := &deadLetter{path: config.DeadLetterPath, encoder: encoder}
+
+	httpConfig.Encoder = encoder
+	httpConfig.ResponseHandler = func(resp *http.Response, records []logrus_http.Record) ([]logrus_http.Record, error) {
+		retry, failed, err := ParseBulkResponse(resp.Body, records)
+		if len(failed) == 0 {
+			return retry, nil
+		}
+		deadLetter.write(failed)
+		return retry, err
+	}
+	httpConfig.OnDrop = func(records []logrus_http.Record, err error) {
+		deadLetter.write(records)
+	}
+	return logrus_http.NewHook(httpConfig)
+}
+
+// Encoder writes `_bulk` request bodies, indexing each record into the index
+// for its date.
+type Encoder struct {
+	Index           string
+	IndexDateLayout string
+}
+
+func (e *Encoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// IndexName returns the index an entry is written to. Dates are in UTC.
+func (e *Encoder) IndexName(entry *logrus.Entry) string {
+	if e.IndexDateLayout == "-" {
+		return e.Index
+	}
+	return e.Index + entry.Time.UTC().Format(e.IndexDateLayout)
+}
+
+func (e *Encoder) Encode(w io.Writer, records []logrus_http.Record) error {
+	var b bytes.Buffer
+	for _, record := range records {
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": e.IndexName(record.Entry)},
+		})
+		if err != nil {
+			return err
+		}
+		b.Write(action)
+		b.WriteByte('\n')
+		b.Write(bytes.TrimRight(record.Formatted, "\n"))
+		b.WriteByte('\n')
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// ParseBulkResponse reads the response to a `_bulk` request indexing
+// records, and returns the records to retry, those failing for good, and an
+// error describing the first of the latter, or else of the former. Items
+// rejected with a 429 status, as when the cluster is overloaded, or a 5xx
+// status are retried.
+func ParseBulkResponse(r io.Reader, records []logrus_http.Record) (retry, failed []logrus_http.Record, err error) {
+	var response bulkResponse
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, nil, fmt.Errorf("elasticsearch: invalid bulk response, %v", err)
+	}
+	if !response.Errors {
+		return nil, nil, nil
+	}
+	if len(response.Items) != len(records) {
+		return nil, records, fmt.Errorf("elasticsearch: bulk response has %d items for %d entries", len(response.Items), len(records))
+	}
+
+	var retryErr error
+	for i, result := range response.Items {
+		for _, item := range result {
+			if item.Status >= 200 && item.Status < 300 {
+				continue
+			}
+			reason := http.StatusText(item.Status)
+			if item.Error != nil {
+				reason = item.Error.Type + ": " + item.Error.Reason
+			}
+			itemErr := fmt.Errorf("elasticsearch: failed to index entry with status %d, %s", item.Status, reason)
+			if item.Status == http.StatusTooManyRequests || item.Status >= 500 {
+				retry = append(retry, records[i])
+				if retryErr == nil {
+					retryErr = itemErr
+				}
+			} else {
+				failed = append(failed, records[i])
+				if err == nil {
+					err = itemErr
+				}
+			}
+		}
+	}
+	if err == nil {
+		err = retryErr
+	}
+	return retry, failed, err
+}
+
+// deadLetter appends records to a file. It's only used from the background
+// goroutine of the hook.
+type deadLetter struct {
+	path    string
+	encoder *Encoder
+}
+
+func (d *deadLetter) write(records []logrus_http.Record) {
+	if d.path == "" {
+		return
+	}
+	var b bytes.Buffer
+	d.encoder.Encode(&b, records)
+
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err == nil {
+		_, err = b.WriteTo(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write to the dead-letter file, %v\n", err)
+	}
+}
@@ -0,0 +1,177 @@
+package logrus_elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkServer imitates the `_bulk` API. Documents with a "reject" field fail
+// with a mapping error, those with an "overload" field are rejected with a
+// 429 status the first time they are seen.
+type bulkServer struct {
+	mu       sync.Mutex
+	requests int
+	indexed  map[string][]map[string]interface{}
+	seen     map[string]bool
+}
+
+func newBulkServer() *bulkServer {
+	return &bulkServer{indexed: make(map[string][]map[string]interface{}), seen: make(map[string]bool)}
+}
+
+func (s *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if r.URL.Path != "/_bulk" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	response := bulkResponse{}
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		var doc map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &doc)
+
+		item := bulkResponseItem{Status: http.StatusCreated}
+		msg, _ := doc["message"].(string)
+		fields, _ := doc["fields"].(map[string]interface{})
+		switch {
+		case fields["reject"] != nil:
+			item.Status = http.StatusBadRequest
+			item.Error = &struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			}{"mapper_parsing_exception", "failed to parse"}
+		case fields["overload"] != nil && !s.seen[msg]:
+			s.seen[msg] = true
+			item.Status = http.StatusTooManyRequests
+		default:
+			index := action["index"]["_index"]
+			s.indexed[index] = append(s.indexed[index], doc)
+		}
+		if item.Status != http.StatusCreated {
+			response.Errors = true
+		}
+		response.Items = append(response.Items, map[string]bulkResponseItem{"index": item})
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func newLogger(hook *logrus_http.Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+func TestBulkIndexing(t *testing.T) {
+	s := newBulkServer()
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "logrus_elasticsearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	deadLetterPath := filepath.Join(dir, "dead-letter.ndjson")
+
+	hook := NewHook(Config{
+		Config:         logrus_http.Config{URL: server.URL + "/", RetryBackoff: time.Millisecond},
+		DeadLetterPath: deadLetterPath,
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.WithField("animal", "walrus").Info("A walrus appears")
+	logger.WithField("overload", true).Info("Busy walrus")
+	logger.WithField("reject", true).Info("Bad walrus")
+
+	err = hook.Flush()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+
+	// The overloaded entry alone was retried.
+	assert.Equal(t, 2, s.requests)
+	today := "logs-" + time.Now().UTC().Format("2006.01.02")
+	assert.Len(t, s.indexed[today], 2)
+	assert.Equal(t, "A walrus appears", s.indexed[today][0]["message"])
+	assert.Equal(t, "Busy walrus", s.indexed[today][1]["message"])
+
+	deadLetter, err := ioutil.ReadFile(deadLetterPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(deadLetter)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, `{"index":{"_index":"`+today+`"}}`, lines[0])
+	assert.Contains(t, lines[1], "Bad walrus")
+}
+
+func TestIndexName(t *testing.T) {
+	entry := &logrus.Entry{Time: time.Date(2017, 7, 14, 23, 0, 0, 0, time.FixedZone("", -2*3600))}
+	assert.Equal(t, "logs-2017.07.15", (&Encoder{Index: "logs-", IndexDateLayout: "2006.01.02"}).IndexName(entry))
+	assert.Equal(t, "logs-2017-07", (&Encoder{Index: "logs-", IndexDateLayout: "2006-01"}).IndexName(entry))
+	assert.Equal(t, "walrus", (&Encoder{Index: "walrus", IndexDateLayout: "-"}).IndexName(entry))
+}
+
+func TestDeadLetterOnRequestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "logrus_elasticsearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	deadLetterPath := filepath.Join(dir, "dead-letter.ndjson")
+
+	hook := NewHook(Config{
+		Config:          logrus_http.Config{URL: server.URL, MaxRetries: 1, RetryBackoff: time.Millisecond},
+		Index:           "walrus",
+		IndexDateLayout: "-",
+		DeadLetterPath:  deadLetterPath,
+	})
+	defer hook.Close()
+	newLogger(hook).Info("Lost walrus")
+	assert.Error(t, hook.Flush())
+
+	deadLetter, err := ioutil.ReadFile(deadLetterPath)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(deadLetter), `{"index":{"_index":"walrus"}}`+"\n"))
+	assert.Contains(t, string(deadLetter), "Lost walrus")
+}
+
+func TestParseBulkResponse(t *testing.T) {
+	records := []logrus_http.Record{{}, {}}
+	_, _, err := ParseBulkResponse(strings.NewReader(`{"errors":true,"items":[]}`), records)
+	assert.Error(t, err)
+
+	retry, failed, err := ParseBulkResponse(strings.NewReader(`{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`), records)
+	assert.NoError(t, err)
+	assert.Empty(t, retry)
+	assert.Empty(t, failed)
+
+	retry, failed, err = ParseBulkResponse(strings.NewReader(`{"errors":true,"items":[{"index":{"status":503}},{"index":{"status":201}}]}`), records)
+	assert.EqualError(t, err, "elasticsearch: failed to index entry with status 503, Service Unavailable")
+	assert.Len(t, retry, 1)
+	assert.Empty(t, failed)
+}
@@ -0,0 +1,404 @@
+package logrus_fluentd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/formatters/msgpack"
+	"github.com/sirupsen/logrus/hooks/internal/batch"
+	"github.com/sirupsen/logrus/internal/record"
+)
+
+// DefaultAddress is the address of a forward input listening with its
+// default configuration on the local host.
+const DefaultAddress = "localhost:24224"
+
+// ErrQueueFull is returned by Fire when entries are logged faster than they
+// can be forwarded and the queue has no room left.
+var ErrQueueFull = errors.New("fluentd: forward queue is full, entry dropped")
+
+// ErrClosed is returned by Fire and Flush once the hook has been closed.
+var ErrClosed = errors.New("fluentd: hook is closed")
+
+// Mode selects the Forward protocol carrier mode used to send entries.
+type Mode int
+
+const (
+	// PackedForward sends each batch as a single MessagePack binary holding
+	// the stream of entries. It is the default and the cheapest to decode.
+	PackedForward Mode = iota
+	// Forward sends each batch as an array of entries.
+	Forward
+	// Message sends each entry on its own, for servers lacking batch support.
+	Message
+)
+
+// Config configures a Hook. The zero value forwards to DefaultAddress over
+// TCP in PackedForward mode.
+type Config struct {
+	// Network is "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Address of the forward input, a host:port pair or a socket path.
+	Address string
+
+	// Tag routes the entries within Fluentd. Defaults to "logrus".
+	Tag string
+	// TagField names a field, such as "logger" or "component", whose value is
+	// appended to Tag: with TagField "logger", an entry with the field
+	// `logger=db` is tagged "logrus.db". Entries without the field use Tag.
+	TagField string
+
+	Mode Mode
+	// DisableEventTime sends times as integer seconds instead of the
+	// nanosecond precision EventTime extension, for servers older than
+	// Fluentd v0.14.
+	DisableEventTime bool
+	// RequireAck asks the server to acknowledge each chunk of entries, which
+	// is only then considered delivered. Chunks not acknowledged within
+	// AckTimeout are sent again, the server dropping duplicates.
+	RequireAck bool
+	AckTimeout time.Duration
+
+	// BatchSize is the maximum number of entries per chunk. Defaults to 256.
+	BatchSize int
+	// FlushInterval is the longest an entry waits for its chunk to fill up
+	// before being sent anyway. Defaults to one second.
+	FlushInterval time.Duration
+	// QueueSize is the number of entries buffered while waiting to be sent,
+	// including while the server is unreachable. Defaults to 8192.
+	QueueSize int
+
+	// DialTimeout and WriteTimeout bound connecting and sending a chunk.
+	// Both default to ten seconds.
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxRetries is the number of times sending a chunk is retried, over a
+	// new connection, before it's dropped. Defaults to 5, a negative value
+	// disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// following attempt up to MaxBackoff. Default to 500ms and 30 seconds.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Levels the hook fires on. Defaults to all levels.
+	Levels []logrus.Level
+}
+
+// event is an entry encoded when fired, as the [time, record] pair of the
+// Forward protocol.
+type event struct {
+	tag  string
+	data []byte
+}
+
+// Hook forwards entries to Fluentd, or Fluent Bit, over the Forward protocol.
+// Entries are encoded when fired and sent in chunks by a background
+// goroutine, so Fire never waits on the network, and the connection is
+// reestablished whenever it breaks. Call Flush to wait until everything fired
+// so far has been sent, and Close when done with the hook. Until then,
+// pending entries are flushed on exit, see logrus.RegisterExitFlusher.
+type Hook struct {
+	config  Config
+	conn    net.Conn
+	batcher *batch.Batcher
+}
+
+// NewHook creates a hook forwarding to the server described by config and
+// starts its background sender. The connection is established on the first
+// chunk. Add it to a logger with `log.Hooks.Add(hook)`.
+func NewHook(config Config) *Hook {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+	if config.Tag == "" {
+		config.Tag = "logrus"
+	}
+	if config.AckTimeout <= 0 {
+		config.AckTimeout = 10 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 256
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 8192
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 10 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 10 * time.Second
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.Levels == nil {
+		config.Levels = logrus.AllLevels
+	}
+
+	hook := &Hook{config: config}
+	hook.batcher = batch.New(batch.Config{
+		Name:         "fluentd hook",
+		Size:         config.BatchSize,
+		Interval:     config.FlushInterval,
+		QueueSize:    config.QueueSize,
+		Send:         hook.sendItems,
+		Stop:         hook.closeConn,
+		ErrQueueFull: ErrQueueFull,
+		ErrClosed:    ErrClosed,
+	})
+
+	return hook
+}
+
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.config.Levels
+}
+
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	data, err := hook.encode(entry)
+	if err != nil {
+		return err
+	}
+	return hook.batcher.Add(event{tag: hook.tag(entry), data: data}, len(data))
+}
+
+// Flush sends all the entries fired so far and returns the error of the last
+// chunk that couldn't be delivered, if any.
+func (hook *Hook) Flush() error {
+	return hook.batcher.Flush()
+}
+
+// Close flushes the pending entries, stops the background sender and closes
+// the connection.
+func (hook *Hook) Close() error {
+	return hook.batcher.Close()
+}
+
+func (hook *Hook) tag(entry *logrus.Entry) string {
+	if hook.config.TagField == "" {
+		return hook.config.Tag
+	}
+	if v, ok := entry.Data[hook.config.TagField]; ok {
+		if s := fmt.Sprint(v); s != "" {
+			return hook.config.Tag + "." + s
+		}
+	}
+	return hook.config.Tag
+}
+
+// encode returns the [time, record] pair of an entry. The record holds the
+// fields of the entry along with its `msg` and `level`.
+func (hook *Hook) encode(entry *logrus.Entry) ([]byte, error) {
+	data := make(map[string]interface{}, len(entry.Data)+2)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	record.SetField(data, "msg", entry.Message)
+	record.SetField(data, "level", entry.Level.String())
+
+	b := logrus_msgpack.AppendArrayHeader(make([]byte, 0, 128), 2)
+	var t interface{} = EventTime(entry.Time)
+	if hook.config.DisableEventTime {
+		t = entry.Time.Unix()
+	}
+	b, err := logrus_msgpack.Append(b, t)
+	if err == nil {
+		b, err = logrus_msgpack.Append(b, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fluentd: failed to marshal entry, %v", err)
+	}
+	return b, nil
+}
+
+// EventTimeExt is the MessagePack extension type of EventTime.
+const EventTimeExt = 0
+
+// EventTime returns the Forward protocol EventTime of t: seconds and
+// nanoseconds since the Unix epoch, as two 32 bit big endian integers.
+func EventTime(t time.Time) logrus_msgpack.Ext {
+	sec, nsec := uint32(t.Unix()), uint32(t.Nanosecond())
+	return logrus_msgpack.Ext{
+		Type: EventTimeExt,
+		Data: []byte{
+			byte(sec >> 24), byte(sec >> 16), byte(sec >> 8), byte(sec),
+			byte(nsec >> 24), byte(nsec >> 16), byte(nsec >> 8), byte(nsec),
+		},
+	}
+}
+
+// sendItems sends the events queued by Fire, from the goroutine of the
+// batcher.
+func (hook *Hook) sendItems(items []interface{}, flushing bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+	events := make([]event, len(items))
+	for i, item := range items {
+		events[i] = item.(event)
+	}
+	return hook.send(events)
+}
+
+// closeConn drops the connection, established again by the next write.
+func (hook *Hook) closeConn() {
+	if hook.conn != nil {
+		hook.conn.Close()
+		hook.conn = nil
+	}
+}
+
+// chunk is a message of the Forward protocol, ready to be written.
+type chunk struct {
+	data []byte
+	id   string
+}
+
+// send groups a batch by tag into chunks and writes them, retrying each over
+// a new connection with exponential backoff.
+func (hook *Hook) send(batch []event) error {
+	chunks, err := hook.chunks(batch)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, c := range chunks {
+		backoff := hook.config.RetryBackoff
+		for attempt := 0; ; attempt++ {
+			err := hook.write(c)
+			if err == nil {
+				break
+			}
+			hook.closeConn()
+			if attempt >= hook.config.MaxRetries {
+				lastErr = err
+				break
+			}
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > hook.config.MaxBackoff {
+				backoff = hook.config.MaxBackoff
+			}
+		}
+	}
+	return lastErr
+}
+
+func (hook *Hook) chunks(batch []event) ([]chunk, error) {
+	if hook.config.Mode == Message {
+		chunks := make([]chunk, 0, len(batch))
+		for _, e := range batch {
+			// The [time, record] pair, without its array header.
+			c, err := hook.newChunk(e.tag, 1, 4, e.data[1:])
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, c)
+		}
+		return chunks, nil
+	}
+
+	// Group the events by tag, keeping the order of the tags.
+	var tags []string
+	events := make(map[string][]event)
+	for _, e := range batch {
+		if _, ok := events[e.tag]; !ok {
+			tags = append(tags, e.tag)
+		}
+		events[e.tag] = append(events[e.tag], e)
+	}
+
+	chunks := make([]chunk, 0, len(tags))
+	for _, tag := range tags {
+		var stream []byte
+		for _, e := range events[tag] {
+			stream = append(stream, e.data...)
+		}
+
+		var entries []byte
+		if hook.config.Mode == Forward {
+			entries = append(logrus_msgpack.AppendArrayHeader(nil, len(events[tag])), stream...)
+		} else {
+			var err error
+			if entries, err = logrus_msgpack.Marshal(stream); err != nil {
+				return nil, err
+			}
+		}
+		c, err := hook.newChunk(tag, len(events[tag]), 3, entries)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// newChunk builds a message from its tag, the already encoded entries
+// spanning fields of the message array, and its option map.
+func (hook *Hook) newChunk(tag string, size int, fields int, entries []byte) (chunk, error) {
+	option := map[string]interface{}{"size": size}
+	var c chunk
+	if hook.config.RequireAck {
+		var id [16]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return c, err
+		}
+		c.id = base64.StdEncoding.EncodeToString(id[:])
+		option["chunk"] = c.id
+	}
+
+	b := logrus_msgpack.AppendArrayHeader(nil, fields)
+	b, _ = logrus_msgpack.Append(b, tag)
+	b = append(b, entries...)
+	b, err := logrus_msgpack.Append(b, option)
+	c.data = b
+	return c, err
+}
+
+// write sends a chunk, connecting first if needed, and waits for its
+// acknowledgment when required.
+func (hook *Hook) write(c chunk) error {
+	if hook.conn == nil {
+		conn, err := net.DialTimeout(hook.config.Network, hook.config.Address, hook.config.DialTimeout)
+		if err != nil {
+			return err
+		}
+		hook.conn = conn
+	}
+
+	hook.conn.SetWriteDeadline(time.Now().Add(hook.config.WriteTimeout))
+	if _, err := hook.conn.Write(c.data); err != nil {
+		return err
+	}
+	if c.id == "" {
+		return nil
+	}
+
+	hook.conn.SetReadDeadline(time.Now().Add(hook.config.AckTimeout))
+	response, err := logrus_msgpack.NewDecoder(hook.conn).Decode()
+	if err != nil {
+		return fmt.Errorf("fluentd: no acknowledgment for chunk %s, %v", c.id, err)
+	}
+	if m, ok := response.(map[string]interface{}); !ok || m["ack"] != c.id {
+		return fmt.Errorf("fluentd: unexpected acknowledgment %v for chunk %s", response, c.id)
+	}
+	return nil
+}
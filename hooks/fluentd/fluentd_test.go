@@ -0,0 +1,226 @@
+package logrus_fluentd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/formatters/msgpack"
+	"github.com/stretchr/testify/assert"
+)
+
+// server is a minimal forward input, sending every message it reads over
+// a channel and acknowledging chunks.
+type server struct {
+	listener net.Listener
+	messages chan []interface{}
+}
+
+func newServer(t *testing.T, network, address string) *server {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{listener: listener, messages: make(chan []interface{}, 100)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	d := logrus_msgpack.NewDecoder(conn)
+	for {
+		v, err := d.Decode()
+		if err != nil {
+			return
+		}
+		message := v.([]interface{})
+		option := message[len(message)-1].(map[string]interface{})
+		if id, ok := option["chunk"]; ok {
+			ack, _ := logrus_msgpack.Marshal(map[string]interface{}{"ack": id})
+			conn.Write(ack)
+		}
+		s.messages <- message
+	}
+}
+
+func (s *server) next(t *testing.T) []interface{} {
+	select {
+	case message := <-s.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func newLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+// decodeEntries returns the [time, record] pairs of a Forward or
+// PackedForward message.
+func decodeEntries(t *testing.T, message []interface{}) [][]interface{} {
+	var entries [][]interface{}
+	switch v := message[1].(type) {
+	case []interface{}:
+		for _, e := range v {
+			entries = append(entries, e.([]interface{}))
+		}
+	case []byte:
+		d := logrus_msgpack.NewDecoder(bytes.NewReader(v))
+		for {
+			e, err := d.Decode()
+			if err != nil {
+				break
+			}
+			entries = append(entries, e.([]interface{}))
+		}
+	default:
+		t.Fatalf("unexpected entries %T", v)
+	}
+	return entries
+}
+
+func TestPackedForward(t *testing.T) {
+	s := newServer(t, "tcp", "127.0.0.1:0")
+	defer s.listener.Close()
+
+	hook := NewHook(Config{Address: s.listener.Addr().String(), TagField: "logger"})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.WithField("animal", "walrus").Info("A walrus appears")
+	logger.WithFields(logrus.Fields{"logger": "db", "level": 1}).Warn("Slow query")
+	logger.Error("The ice breaks")
+	assert.NoError(t, hook.Flush())
+
+	message := s.next(t)
+	assert.Equal(t, "logrus", message[0])
+	assert.Equal(t, map[string]interface{}{"size": int64(2)}, message[2])
+	entries := decodeEntries(t, message)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, map[string]interface{}{"animal": "walrus", "msg": "A walrus appears", "level": "info"}, entries[0][1])
+	assert.Equal(t, "The ice breaks", entries[1][1].(map[string]interface{})["msg"])
+
+	ext := entries[0][0].(logrus_msgpack.Ext)
+	assert.Equal(t, int8(EventTimeExt), ext.Type)
+	assert.Len(t, ext.Data, 8)
+
+	message = s.next(t)
+	assert.Equal(t, "logrus.db", message[0])
+	entries = decodeEntries(t, message)
+	assert.Equal(t, map[string]interface{}{"logger": "db", "fields.level": int64(1), "msg": "Slow query", "level": "warning"}, entries[0][1])
+}
+
+func TestForwardWithAck(t *testing.T) {
+	s := newServer(t, "tcp", "127.0.0.1:0")
+	defer s.listener.Close()
+
+	hook := NewHook(Config{
+		Address:          s.listener.Addr().String(),
+		Mode:             Forward,
+		RequireAck:       true,
+		DisableEventTime: true,
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("A walrus appears")
+	assert.NoError(t, hook.Flush())
+
+	message := s.next(t)
+	assert.Len(t, message, 3)
+	assert.NotEmpty(t, message[2].(map[string]interface{})["chunk"])
+	entries := decodeEntries(t, message)
+	assert.Len(t, entries, 1)
+	assert.IsType(t, int64(0), entries[0][0])
+}
+
+func TestMessageMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrus_fluentd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "fluentd.sock")
+
+	s := newServer(t, "unix", socket)
+	defer s.listener.Close()
+
+	hook := NewHook(Config{Network: "unix", Address: socket, Mode: Message})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	entry := logger.WithField("animal", "walrus")
+	entry.Time = time.Unix(1500000000, 42)
+	entry.Info("A walrus appears")
+	logger.Info("Another walrus")
+	assert.NoError(t, hook.Flush())
+
+	message := s.next(t)
+	assert.Len(t, message, 4)
+	assert.Equal(t, "logrus", message[0])
+	assert.Equal(t, "A walrus appears", message[2].(map[string]interface{})["msg"])
+	assert.Equal(t, "Another walrus", s.next(t)[2].(map[string]interface{})["msg"])
+}
+
+func TestEventTime(t *testing.T) {
+	ext := EventTime(time.Unix(1500000000, 42))
+	assert.Equal(t, []byte{0x59, 0x68, 0x2f, 0x00, 0, 0, 0, 42}, ext.Data)
+}
+
+func TestReconnect(t *testing.T) {
+	s := newServer(t, "tcp", "127.0.0.1:0")
+	address := s.listener.Addr().String()
+
+	hook := NewHook(Config{Address: address, RetryBackoff: 10 * time.Millisecond, MaxRetries: 50})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("first")
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, "first", decodeEntries(t, s.next(t))[0][1].(map[string]interface{})["msg"])
+
+	// Drop the connection and restart the server on the same address. The
+	// write over the closed connection fails right away, and the retry
+	// connects to the new server.
+	s.listener.Close()
+	hook.conn.Close()
+	s = newServer(t, "tcp", address)
+	defer s.listener.Close()
+
+	logger.Info("second")
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, "second", decodeEntries(t, s.next(t))[0][1].(map[string]interface{})["msg"])
+}
+
+func TestUnreachable(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+
+	hook := NewHook(Config{Address: address, MaxRetries: -1})
+	logger := newLogger(hook)
+
+	logger.Info("lost")
+	assert.Error(t, hook.Flush())
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, ErrClosed, hook.Fire(logrus.NewEntry(logger)))
+}
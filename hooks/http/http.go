@@ -0,0 +1,387 @@
+package logrus_http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/internal/batch"
+	"github.com/sirupsen/logrus/hooks/internal/httpretry"
+)
+
+// ErrQueueFull is returned by Fire when entries are logged faster than they
+// can be shipped and the queue has no room left.
+var ErrQueueFull = errors.New("http: shipping queue is full, entry dropped")
+
+// ErrClosed is returned by Fire and Flush once the hook has been closed.
+var ErrClosed = errors.New("http: hook is closed")
+
+// Record is an entry as it was when fired, along with its formatted bytes.
+type Record struct {
+	// Entry is a copy of the fired entry, safe to use from the background
+	// goroutine of the hook.
+	Entry *logrus.Entry
+	// Formatted is the output of the Formatter of the hook for Entry.
+	Formatted []byte
+}
+
+// An Encoder writes the body of the request shipping a batch of records.
+// Implement it to adapt the hook to the intake format of a service.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, records []Record) error
+}
+
+// NDJSONEncoder writes the formatted records one per line.
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (NDJSONEncoder) Encode(w io.Writer, records []Record) error {
+	for _, record := range records {
+		if _, err := w.Write(bytes.TrimRight(record.Formatted, "\n")); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONArrayEncoder writes the formatted records as the items of a JSON array.
+// The formatter must output JSON.
+type JSONArrayEncoder struct{}
+
+func (JSONArrayEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (JSONArrayEncoder) Encode(w io.Writer, records []Record) error {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, record := range records {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(bytes.TrimSpace(record.Formatted))
+	}
+	b.WriteByte(']')
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// Config configures a Hook.
+type Config struct {
+	// URL the batches are sent to.
+	URL string
+	// Method of the requests. Defaults to "POST".
+	Method string
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string
+
+	// Formatter formats each entry when fired. Defaults to
+	// `logrus.JSONFormatter`.
+	Formatter logrus.Formatter
+	// Encoder writes the request bodies. Defaults to NDJSONEncoder.
+	Encoder Encoder
+	// Gzip compresses the request bodies.
+	Gzip bool
+
+	// BatchSize is the maximum number of entries per request. Defaults to 500.
+	BatchSize int
+	// BatchBytes is the maximum size of the formatted entries of a request,
+	// before encoding and compression. Defaults to 1MiB.
+	BatchBytes int
+	// FlushInterval is the longest an entry waits for its batch to fill up
+	// before being sent anyway. Defaults to one second.
+	FlushInterval time.Duration
+	// QueueSize is the number of entries buffered while waiting to be sent.
+	// Defaults to 4096.
+	QueueSize int
+
+	// MaxRetries is the number of times a failed request is retried before
+	// its batch is dropped. Defaults to 5, a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// following attempt up to MaxBackoff, with random jitter. Default to
+	// 200ms and 30 seconds. A longer Retry-After from the server wins, up to
+	// MaxBackoff.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Client is used for the requests. Defaults to a client with a ten second
+	// timeout.
+	Client *http.Client
+
+	// ResponseHandler, when set, inspects the successful (2xx) responses of
+	// intakes reporting failures per entry. It returns the records to send
+	// again, which are retried like failed requests, and an error describing
+	// the records rejected for good, if any, which Flush reports. Keeping
+	// track of those records is up to the handler.
+	ResponseHandler func(resp *http.Response, records []Record) (retry []Record, err error)
+	// OnDrop, when set, is called from the background goroutine with the
+	// records given up on, and the error that caused it.
+	OnDrop func(records []Record, err error)
+
+	// Levels the hook fires on. Defaults to all levels.
+	Levels []logrus.Level
+}
+
+// Hook ships entries to an HTTP endpoint in batches. Entries are formatted
+// when fired and sent by a background goroutine, so Fire never waits on the
+// network. Requests failing with a network error or a 408, 429 or 5xx status
+// are retried, and the entries still failing after MaxRetries are dropped.
+// Call Flush to wait until everything fired so far has been sent, and Close
+// when done with the hook. Until then, pending entries are flushed on exit,
+// see logrus.RegisterExitFlusher.
+type Hook struct {
+	config  Config
+	batcher *batch.Batcher
+}
+
+// NewHook creates a hook shipping to the endpoint described by config and
+// starts its background sender. Add it to a logger with
+// `log.Hooks.Add(hook)`.
+func NewHook(config Config) *Hook {
+	if config.Method == "" {
+		config.Method = "POST"
+	}
+	if config.Formatter == nil {
+		config.Formatter = &logrus.JSONFormatter{}
+	}
+	if config.Encoder == nil {
+		config.Encoder = NDJSONEncoder{}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.BatchBytes <= 0 {
+		config.BatchBytes = 1 << 20
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 4096
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 200 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Levels == nil {
+		config.Levels = logrus.AllLevels
+	}
+
+	hook := &Hook{config: config}
+	hook.batcher = batch.New(batch.Config{
+		Name:         "http hook",
+		Size:         config.BatchSize,
+		Bytes:        config.BatchBytes,
+		Interval:     config.FlushInterval,
+		QueueSize:    config.QueueSize,
+		Send:         hook.sendItems,
+		ErrQueueFull: ErrQueueFull,
+		ErrClosed:    ErrClosed,
+	})
+
+	return hook
+}
+
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.config.Levels
+}
+
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	record, err := NewRecord(entry, hook.config.Formatter)
+	if err != nil {
+		return err
+	}
+	return hook.batcher.Add(record, len(record.Formatted))
+}
+
+// NewRecord snapshots an entry and formats it with formatter, which may be
+// nil for encoders not using the formatted bytes.
+func NewRecord(entry *logrus.Entry, formatter logrus.Formatter) (Record, error) {
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	snapshot := *entry
+	snapshot.Data = data
+	snapshot.Buffer = nil
+
+	record := Record{Entry: &snapshot}
+	if formatter != nil {
+		formatted, err := formatter.Format(&snapshot)
+		if err != nil {
+			return record, err
+		}
+		// The formatter may reuse its buffer.
+		record.Formatted = append([]byte(nil), formatted...)
+	}
+	return record, nil
+}
+
+// Flush sends all the entries fired so far and returns the error of the last
+// failed request, if any.
+func (hook *Hook) Flush() error {
+	return hook.batcher.Flush()
+}
+
+// Close flushes the pending entries and stops the background sender.
+func (hook *Hook) Close() error {
+	return hook.batcher.Close()
+}
+
+// sendItems ships the records queued by Fire, from the goroutine of the
+// batcher.
+func (hook *Hook) sendItems(items []interface{}, flushing bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+	records := make([]Record, len(items))
+	for i, item := range items {
+		records[i] = item.(Record)
+	}
+	return hook.send(records)
+}
+
+// send ships a batch, retrying with exponential backoff and jitter.
+func (hook *Hook) send(batch []Record) error {
+	// rejected is the error of the records the ResponseHandler gave up on,
+	// reported even if the retried ones are accepted later.
+	var rejected error
+	backoff := hook.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := hook.post(batch)
+		if r, ok := err.(rejectedError); ok {
+			rejected, err = r.err, nil
+		}
+		if len(retry) == 0 {
+			if err == nil {
+				err = rejected
+			}
+			return err
+		}
+		if retryAfter < 0 || attempt >= hook.config.MaxRetries {
+			if err == nil {
+				err = fmt.Errorf("http: %d entries still rejected after %d retries", len(retry), attempt)
+			}
+			if hook.config.OnDrop != nil {
+				hook.config.OnDrop(retry, err)
+			}
+			return err
+		}
+		// Wait between half and all of the backoff, so clients failing
+		// together don't retry together.
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if retryAfter > hook.config.MaxBackoff {
+			retryAfter = hook.config.MaxBackoff
+		}
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		time.Sleep(delay)
+		if backoff *= 2; backoff > hook.config.MaxBackoff {
+			backoff = hook.config.MaxBackoff
+		}
+		batch = retry
+	}
+}
+
+func (hook *Hook) encode(batch []Record) ([]byte, error) {
+	var body bytes.Buffer
+	var w io.Writer = &body
+	var zw *gzip.Writer
+	if hook.config.Gzip {
+		zw = gzip.NewWriter(&body)
+		w = zw
+	}
+	if err := hook.config.Encoder.Encode(w, batch); err != nil {
+		return nil, fmt.Errorf("http: failed to encode entries, %v", err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// rejectedError wraps the error returned by the ResponseHandler for the
+// records it gave up on, telling it apart from the error of a failed request.
+type rejectedError struct {
+	err error
+}
+
+func (e rejectedError) Error() string {
+	return e.err.Error()
+}
+
+// post sends one request and returns the records to retry, along with the
+// minimum delay requested by the server and the error of the request. A
+// negative delay means the records must not be retried. For successful
+// requests, the error returned by the ResponseHandler is wrapped in a
+// rejectedError.
+func (hook *Hook) post(batch []Record) (retry []Record, delay time.Duration, err error) {
+	body, err := hook.encode(batch)
+	if err != nil {
+		return batch, -1, err
+	}
+	req, err := http.NewRequest(hook.config.Method, hook.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return batch, -1, err
+	}
+	req.Header.Set("Content-Type", hook.config.Encoder.ContentType())
+	if hook.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range hook.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := hook.config.Client.Do(req)
+	if err != nil {
+		return batch, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if hook.config.ResponseHandler == nil {
+			return nil, 0, nil
+		}
+		retry, err = hook.config.ResponseHandler(resp, batch)
+		if err != nil {
+			err = rejectedError{err}
+		}
+		return retry, 0, err
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("http: request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return batch, httpretry.After(resp.Header.Get("Retry-After")), err
+	}
+	return batch, -1, err
+}
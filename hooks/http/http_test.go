@@ -0,0 +1,295 @@
+package logrus_http
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// intake records the requests it receives, answering them with the
+// given statuses in turn and then with 200.
+type intake struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (i *intake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		body, _ = gzip.NewReader(r.Body)
+	}
+	b, _ := ioutil.ReadAll(body)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.requests = append(i.requests, r)
+	i.bodies = append(i.bodies, string(b))
+	if len(i.statuses) > 0 {
+		status := i.statuses[0]
+		i.statuses = i.statuses[1:]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func newLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+func TestNDJSON(t *testing.T) {
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer walrus"},
+		Formatter: &logrus.JSONFormatter{DisableTimestamp: true},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	entry := logger.WithField("animal", "walrus")
+	entry.Info("A walrus appears")
+	entry.Data["animal"] = "orca"
+	logger.Warn("The ice breaks")
+	assert.NoError(t, hook.Flush())
+
+	assert.Len(t, in.requests, 1)
+	assert.Equal(t, "POST", in.requests[0].Method)
+	assert.Equal(t, "application/x-ndjson", in.requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer walrus", in.requests[0].Header.Get("Authorization"))
+
+	lines := strings.Split(strings.TrimSuffix(in.bodies[0], "\n"), "\n")
+	assert.Len(t, lines, 2)
+	var first map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "walrus", first["animal"])
+	assert.Equal(t, "A walrus appears", first["msg"])
+}
+
+func TestJSONArrayGzip(t *testing.T) {
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, Encoder: JSONArrayEncoder{}, Gzip: true})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("first")
+	logger.Info("second")
+	assert.NoError(t, hook.Flush())
+
+	assert.Equal(t, "gzip", in.requests[0].Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", in.requests[0].Header.Get("Content-Type"))
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(in.bodies[0]), &items))
+	assert.Len(t, items, 2)
+	assert.Equal(t, "second", items[1]["msg"])
+}
+
+func TestBatchLimits(t *testing.T) {
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, BatchSize: 2, FlushInterval: time.Hour})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	for i := 0; i < 5; i++ {
+		logger.Info("walrus")
+	}
+	assert.NoError(t, hook.Flush())
+	assert.Len(t, in.requests, 3)
+
+	in.requests, in.bodies = nil, nil
+	hook = NewHook(Config{URL: server.URL, BatchBytes: 1, FlushInterval: time.Hour})
+	defer hook.Close()
+	logger = newLogger(hook)
+
+	logger.Info("first")
+	logger.Info("second")
+	assert.NoError(t, hook.Flush())
+	assert.Len(t, in.requests, 2)
+}
+
+func TestFlushInterval(t *testing.T) {
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, FlushInterval: 10 * time.Millisecond})
+	defer hook.Close()
+	newLogger(hook).Info("A walrus appears")
+
+	for i := 0; i < 100; i++ {
+		in.mu.Lock()
+		n := len(in.requests)
+		in.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("the batch wasn't sent")
+}
+
+func TestRetries(t *testing.T) {
+	in := &intake{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, RetryBackoff: time.Millisecond})
+	defer hook.Close()
+	newLogger(hook).Info("A walrus appears")
+
+	assert.NoError(t, hook.Flush())
+	assert.Len(t, in.requests, 3)
+	assert.Equal(t, in.bodies[0], in.bodies[2])
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if requests++; requests == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, RetryBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer hook.Close()
+	newLogger(hook).Info("A walrus appears")
+
+	start := time.Now()
+	assert.NoError(t, hook.Flush())
+	assert.True(t, time.Since(start) < time.Second, "waited for the Retry-After of the server")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, requests)
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	in := &intake{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	hook := NewHook(Config{URL: server.URL, RetryBackoff: time.Millisecond})
+	defer hook.Close()
+	newLogger(hook).Info("A walrus appears")
+
+	assert.Error(t, hook.Flush())
+	assert.Len(t, in.requests, 1)
+}
+
+func TestClosed(t *testing.T) {
+	hook := NewHook(Config{URL: "http://127.0.0.1:1"})
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, ErrClosed, hook.Fire(logrus.NewEntry(logrus.New())))
+	assert.Equal(t, ErrClosed, hook.Flush())
+}
+
+// TestFlushOnExit runs itself in a child process logging a fatal entry,
+// which must reach the intake before the process exits.
+func TestFlushOnExit(t *testing.T) {
+	if url := os.Getenv("LOGRUS_HTTP_EXIT_URL"); url != "" {
+		hook := NewHook(Config{URL: url, FlushInterval: time.Hour})
+		logrus.AddHook(hook)
+		logrus.SetOutput(ioutil.Discard)
+		logrus.Fatal("The ice breaks")
+		return
+	}
+
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestFlushOnExit")
+	cmd.Env = append(os.Environ(), "LOGRUS_HTTP_EXIT_URL="+server.URL)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("completed normally, should have exited")
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	assert.Len(t, in.requests, 1)
+	assert.Contains(t, in.bodies[0], "The ice breaks")
+}
+
+func TestResponseHandler(t *testing.T) {
+	in := &intake{}
+	server := httptest.NewServer(in)
+	defer server.Close()
+
+	// Retry the entries holding a "retry" field, reject those holding a
+	// "reject" field.
+	requests := 0
+	var dropped []Record
+	var dropErr error
+	hook := NewHook(Config{
+		URL:          server.URL,
+		RetryBackoff: time.Millisecond,
+		MaxRetries:   2,
+		ResponseHandler: func(resp *http.Response, records []Record) ([]Record, error) {
+			requests++
+			var retry []Record
+			var err error
+			for _, record := range records {
+				if _, ok := record.Entry.Data["retry"]; ok {
+					retry = append(retry, record)
+				}
+				if _, ok := record.Entry.Data["reject"]; ok {
+					err = errors.New("rejected")
+				}
+			}
+			return retry, err
+		},
+		OnDrop: func(records []Record, err error) {
+			dropped = append(dropped, records...)
+			dropErr = err
+		},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("accepted")
+	logger.WithField("reject", true).Info("rejected")
+	assert.EqualError(t, hook.Flush(), "rejected")
+	assert.Equal(t, 1, requests)
+
+	logger.Info("accepted")
+	logger.WithField("retry", true).Info("retried")
+	assert.EqualError(t, hook.Flush(), "http: 1 entries still rejected after 2 retries")
+	assert.Equal(t, 4, requests)
+	assert.Contains(t, in.bodies[1], "accepted")
+	assert.NotContains(t, in.bodies[2], "accepted")
+	assert.Len(t, dropped, 1)
+	assert.Equal(t, "retried", dropped[0].Entry.Message)
+	assert.EqualError(t, dropErr, "http: 1 entries still rejected after 2 retries")
+}
@@ -0,0 +1,177 @@
+// Package batch runs the background goroutine of the hooks sending entries in
+// batches: items are queued when fired, and sent once their batch is full,
+// after waiting for an interval, or on a flush.
+package batch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config configures a Batcher.
+type Config struct {
+	// Name of the exit handler flushing the batcher, see
+	// logrus.RegisterExitFlusher.
+	Name string
+
+	// Size is the maximum number of items per batch.
+	Size int
+	// Bytes, when positive, is the maximum total size of the items of a
+	// batch. Larger items are sent in a batch of their own.
+	Bytes int
+	// Interval is the longest an item waits for its batch to fill up.
+	Interval time.Duration
+	// QueueSize is the number of items buffered for the goroutine.
+	QueueSize int
+
+	// Send is called by the goroutine with each full batch and, with the
+	// items queued so far, possibly none, every Interval and on Flush, with
+	// flushing set then. The last error it returns before a Flush is
+	// returned by Flush.
+	Send func(items []interface{}, flushing bool) error
+	// Stop, when set, is called by the goroutine when it stops, to release
+	// what Send uses.
+	Stop func()
+
+	// ErrQueueFull is returned by Add when the queue has no room left, and
+	// ErrClosed by Add and Flush once the batcher has been closed.
+	ErrQueueFull error
+	ErrClosed    error
+}
+
+type item struct {
+	value interface{}
+	size  int
+}
+
+// Batcher queues items and sends them in batches from its goroutine. It is
+// flushed on exit until closed.
+type Batcher struct {
+	config Config
+
+	queue   chan item
+	flushes chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	exitHandle logrus.ExitHandle
+}
+
+// New starts the goroutine of a batcher and registers its exit flush.
+func New(config Config) *Batcher {
+	b := &Batcher{
+		config:  config,
+		queue:   make(chan item, config.QueueSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run()
+	b.exitHandle = logrus.RegisterExitFlusher(config.Name, b)
+	return b
+}
+
+// Add queues value, of the given size in bytes, without waiting.
+func (b *Batcher) Add(value interface{}, size int) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return b.config.ErrClosed
+	}
+
+	select {
+	case b.queue <- item{value: value, size: size}:
+		return nil
+	default:
+		return b.config.ErrQueueFull
+	}
+}
+
+// Flush sends all the items added so far, and returns the last error of Send
+// since the previous Flush.
+func (b *Batcher) Flush() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return b.config.ErrClosed
+	}
+
+	reply := make(chan error)
+	b.flushes <- reply
+	return <-reply
+}
+
+// Close flushes the pending items, stops the goroutine and deregisters the
+// exit flush.
+func (b *Batcher) Close() error {
+	b.exitHandle.Deregister()
+	err := b.Flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return err
+	}
+	b.closed = true
+	close(b.done)
+	<-b.stopped
+	return err
+}
+
+func (b *Batcher) run() {
+	defer close(b.stopped)
+	if b.config.Stop != nil {
+		defer b.config.Stop()
+	}
+
+	ticker := time.NewTicker(b.config.Interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	var batch []interface{}
+	size := 0
+	send := func(flushing bool) {
+		if err := b.config.Send(batch, flushing); err != nil {
+			lastErr = err
+		}
+		batch, size = nil, 0
+	}
+	add := func(it item) {
+		if b.config.Bytes > 0 && len(batch) > 0 && size+it.size > b.config.Bytes {
+			send(false)
+		}
+		batch = append(batch, it.value)
+		size += it.size
+		if len(batch) >= b.config.Size || (b.config.Bytes > 0 && size >= b.config.Bytes) {
+			send(false)
+		}
+	}
+
+	for {
+		select {
+		case it := <-b.queue:
+			add(it)
+		case <-ticker.C:
+			send(false)
+		case reply := <-b.flushes:
+			for drained := false; !drained; {
+				select {
+				case it := <-b.queue:
+					add(it)
+				default:
+					drained = true
+				}
+			}
+			send(true)
+			reply <- lastErr
+			lastErr = nil
+		case <-b.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errQueueFull = errors.New("queue full")
+	errClosed    = errors.New("closed")
+)
+
+// sent records the batches given to Send.
+type sent struct {
+	mu       sync.Mutex
+	batches  [][]interface{}
+	flushing []bool
+}
+
+func (s *sent) send(items []interface{}, flushing bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(items) > 0 {
+		s.batches = append(s.batches, items)
+		s.flushing = append(s.flushing, flushing)
+	}
+	return nil
+}
+
+func (s *sent) get() ([][]interface{}, []bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches, s.flushing
+}
+
+func newBatcher(config Config) *Batcher {
+	if config.Size == 0 {
+		config.Size = 100
+	}
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+	config.Name = "test batcher"
+	config.ErrQueueFull, config.ErrClosed = errQueueFull, errClosed
+	return New(config)
+}
+
+func TestBatchSizes(t *testing.T) {
+	s := &sent{}
+	b := newBatcher(Config{Size: 2, Bytes: 10, QueueSize: 10, Send: s.send})
+	defer b.Close()
+
+	assert.NoError(t, b.Add("a", 1))
+	assert.NoError(t, b.Add("b", 1))
+	assert.NoError(t, b.Add("c", 8))
+	assert.NoError(t, b.Add("d", 5))
+	assert.NoError(t, b.Add("e", 20))
+	assert.NoError(t, b.Add("f", 1))
+	assert.NoError(t, b.Flush())
+
+	batches, flushing := s.get()
+	assert.Equal(t, [][]interface{}{{"a", "b"}, {"c"}, {"d"}, {"e"}, {"f"}}, batches)
+	assert.Equal(t, []bool{false, false, false, false, true}, flushing)
+}
+
+func TestBatchInterval(t *testing.T) {
+	sends := make(chan []interface{}, 10)
+	b := newBatcher(Config{Interval: time.Millisecond, QueueSize: 10, Send: func(items []interface{}, flushing bool) error {
+		if len(items) > 0 {
+			sends <- items
+		}
+		return nil
+	}})
+	defer b.Close()
+
+	assert.NoError(t, b.Add("a", 1))
+	select {
+	case items := <-sends:
+		assert.Equal(t, []interface{}{"a"}, items)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not sent after its interval")
+	}
+}
+
+func TestBatchFlushError(t *testing.T) {
+	failure := errors.New("unreachable")
+	b := newBatcher(Config{Size: 1, QueueSize: 10, Send: func(items []interface{}, flushing bool) error {
+		if len(items) > 0 && items[0] == "bad" {
+			return failure
+		}
+		return nil
+	}})
+	defer b.Close()
+
+	assert.NoError(t, b.Add("bad", 1))
+	assert.NoError(t, b.Add("good", 1))
+	assert.Equal(t, failure, b.Flush())
+	assert.NoError(t, b.Flush())
+}
+
+func TestBatchQueueFullAndClosed(t *testing.T) {
+	entered, release := make(chan struct{}, 10), make(chan struct{})
+	stops := 0
+	b := newBatcher(Config{
+		Size:      1,
+		QueueSize: 1,
+		Send: func(items []interface{}, flushing bool) error {
+			if len(items) > 0 {
+				entered <- struct{}{}
+				<-release
+			}
+			return nil
+		},
+		Stop: func() { stops++ },
+	})
+
+	assert.NoError(t, b.Add("sending", 1))
+	<-entered
+	assert.NoError(t, b.Add("queued", 1))
+	assert.Equal(t, errQueueFull, b.Add("dropped", 1))
+	close(release)
+
+	assert.NoError(t, b.Close())
+	assert.Equal(t, 1, stops)
+	assert.Equal(t, errClosed, b.Add("late", 1))
+	assert.Equal(t, errClosed, b.Flush())
+	assert.Equal(t, errClosed, b.Close())
+	assert.Equal(t, 1, stops)
+}
+
+func TestBatchExitFlush(t *testing.T) {
+	logrus.RunExitHandlers(context.Background())
+
+	s := &sent{}
+	b := newBatcher(Config{QueueSize: 10, Send: s.send})
+	defer b.Close()
+	assert.NoError(t, b.Add("a", 1))
+
+	reports := logrus.RunExitHandlers(context.Background())
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, "test batcher", reports[0].Name)
+		assert.NoError(t, reports[0].Err)
+	}
+	batches, _ := s.get()
+	assert.Equal(t, [][]interface{}{{"a"}}, batches)
+
+	// Closed batchers don't stay registered
+	closed := newBatcher(Config{Send: s.send})
+	assert.NoError(t, closed.Close())
+	assert.Empty(t, logrus.RunExitHandlers(context.Background()))
+}
@@ -0,0 +1,23 @@
+// Package httpretry holds the retry helpers shared by the hooks shipping
+// entries over HTTP.
+package httpretry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// After parses the value of a Retry-After header, given in seconds or as an
+// HTTP date, and returns zero when it's missing, invalid or in the past.
+func After(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(time.Now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,18 @@
+package httpretry
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, After("3"))
+	assert.Equal(t, time.Duration(0), After(""))
+	assert.Equal(t, time.Duration(0), After("soon"))
+	d := After(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 50*time.Second && d <= time.Minute, "%v", d)
+	assert.Equal(t, time.Duration(0), After(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+}
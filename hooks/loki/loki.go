@@ -0,0 +1,339 @@
+package logrus_loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/http"
+)
+
+// DefaultURL is the push API of a Loki running with its default
+// configuration on the local host.
+const DefaultURL = "http://localhost:3100/loki/api/v1/push"
+
+// LevelLabel is the label holding the level of the entries.
+const LevelLabel = "level"
+
+// OtherLabelValue replaces the label values left out by Config.LabelValues.
+const OtherLabelValue = "other"
+
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Config configures a Loki hook. The embedded HTTP hook configuration sets
+// the batching and retries; its URL defaults to DefaultURL and its Formatter,
+// which formats the line of each entry, to a TextFormatter without colors or
+// timestamp. Its Encoder is ignored.
+type Config struct {
+	logrus_http.Config
+
+	// TenantID is sent as the X-Scope-OrgID header, for multi-tenant Loki.
+	TenantID string
+
+	// Labels are the names of the fields used as labels, along with the
+	// level. They are removed from the line of the entries. Every distinct
+	// set of label values creates a stream in Loki, so only fields taking a
+	// few values, such as `app` or `component`, should be labels.
+	Labels []string
+	// StaticLabels are added to every stream, e.g. `job` or `env`.
+	StaticLabels map[string]string
+	// LabelValues lists the values some labels may take. Other values
+	// become OtherLabelValue, keeping the number of streams bounded, and
+	// the field stays in the line.
+	LabelValues map[string][]string
+	// MaxLabelValueLength is the length in bytes beyond which label values
+	// are truncated, the field staying in the line. Defaults to 128, a
+	// negative value disables it.
+	MaxLabelValueLength int
+
+	// Protobuf sends snappy compressed protobuf payloads, which are smaller
+	// and cheaper for Loki to decode, instead of JSON.
+	Protobuf bool
+}
+
+// NewHook creates a hook pushing to the Loki described by config, grouping
+// the entries into streams by their labels. It fails when a label name isn't
+// valid for Loki.
+func NewHook(config Config) (*logrus_http.Hook, error) {
+	for _, name := range config.Labels {
+		if err := validateLabel(name); err != nil {
+			return nil, err
+		}
+	}
+	for name := range config.StaticLabels {
+		if err := validateLabel(name); err != nil {
+			return nil, err
+		}
+	}
+	if config.MaxLabelValueLength == 0 {
+		config.MaxLabelValueLength = 128
+	}
+
+	httpConfig := config.Config
+	if httpConfig.URL == "" {
+		httpConfig.URL = DefaultURL
+	}
+	if httpConfig.Formatter == nil {
+		httpConfig.Formatter = &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true}
+	}
+	encoder := &Encoder{
+		Labels:              config.Labels,
+		StaticLabels:        config.StaticLabels,
+		LabelValues:         config.LabelValues,
+		MaxLabelValueLength: config.MaxLabelValueLength,
+		Protobuf:            config.Protobuf,
+	}
+	httpConfig.Formatter = &lineFormatter{encoder: encoder, formatter: httpConfig.Formatter}
+	httpConfig.Encoder = encoder
+	if config.TenantID != "" {
+		headers := make(map[string]string, len(httpConfig.Headers)+1)
+		for k, v := range httpConfig.Headers {
+			headers[k] = v
+		}
+		headers["X-Scope-OrgID"] = config.TenantID
+		httpConfig.Headers = headers
+	}
+	return logrus_http.NewHook(httpConfig), nil
+}
+
+func validateLabel(name string) error {
+	if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("loki: invalid label name %q", name)
+	}
+	if name == LevelLabel {
+		return fmt.Errorf("loki: label %q is reserved for the level", name)
+	}
+	return nil
+}
+
+// lineFormatter formats entries without the fields whose value is their
+// label.
+type lineFormatter struct {
+	encoder   *Encoder
+	formatter logrus.Formatter
+}
+
+func (f *lineFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	stripped := *entry
+	stripped.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		stripped.Data[k] = v
+	}
+	for _, name := range f.encoder.Labels {
+		if v, ok := entry.Data[name]; ok {
+			if _, exact := f.encoder.labelValue(name, v); exact {
+				delete(stripped.Data, name)
+			}
+		}
+	}
+	return f.formatter.Format(&stripped)
+}
+
+// Encoder writes the push API payloads. The lines are the formatted records,
+// the labels come from the entries. LabelValues and MaxLabelValueLength are
+// those of Config, zero meaning no limit.
+type Encoder struct {
+	Labels              []string
+	StaticLabels        map[string]string
+	LabelValues         map[string][]string
+	MaxLabelValueLength int
+	Protobuf            bool
+}
+
+// labelValue returns the value of the label name for the field value v,
+// and whether it's v in full.
+func (e *Encoder) labelValue(name string, v interface{}) (string, bool) {
+	value := fmt.Sprint(v)
+	if allowed, ok := e.LabelValues[name]; ok {
+		found := false
+		for _, a := range allowed {
+			if a == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return OtherLabelValue, false
+		}
+	}
+	if e.MaxLabelValueLength > 0 && len(value) > e.MaxLabelValueLength {
+		n := e.MaxLabelValueLength
+		// Don't split a UTF-8 sequence
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		return value[:n], false
+	}
+	return value, true
+}
+
+func (e *Encoder) ContentType() string {
+	if e.Protobuf {
+		return "application/x-protobuf"
+	}
+	return "application/json"
+}
+
+func (e *Encoder) Encode(w io.Writer, records []logrus_http.Record) error {
+	streams := e.Streams(records)
+	if !e.Protobuf {
+		return json.NewEncoder(w).Encode(newJSONPush(streams))
+	}
+	_, err := w.Write(snappyEncode(nil, marshalPush(streams)))
+	return err
+}
+
+// Stream is a set of entries sharing the same labels.
+type Stream struct {
+	Labels  map[string]string
+	Entries []Entry
+}
+
+// Entry is a line of a Stream.
+type Entry struct {
+	Time time.Time
+	Line string
+}
+
+// Streams groups records by labels, in the order the streams first appear,
+// each stream sorted by time as Loki expects.
+func (e *Encoder) Streams(records []logrus_http.Record) []*Stream {
+	var streams []*Stream
+	byKey := make(map[string]*Stream)
+	for _, record := range records {
+		labels := make(map[string]string, len(e.Labels)+len(e.StaticLabels)+1)
+		for k, v := range e.StaticLabels {
+			labels[k] = v
+		}
+		for _, name := range e.Labels {
+			if v, ok := record.Entry.Data[name]; ok {
+				labels[name], _ = e.labelValue(name, v)
+			}
+		}
+		labels[LevelLabel] = record.Entry.Level.String()
+
+		key := labelString(labels)
+		stream, ok := byKey[key]
+		if !ok {
+			stream = &Stream{Labels: labels}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Entries = append(stream.Entries, Entry{
+			Time: record.Entry.Time,
+			Line: string(bytes.TrimRight(record.Formatted, "\n")),
+		})
+	}
+	for _, stream := range streams {
+		sort.Stable(byTime(stream.Entries))
+	}
+	return streams
+}
+
+type byTime []Entry
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// labelString returns labels in the Prometheus text format used by the
+// protobuf payloads, such as `{app="api", level="info"}`.
+func labelString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+type jsonPush struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newJSONPush(streams []*Stream) jsonPush {
+	push := jsonPush{Streams: make([]jsonStream, 0, len(streams))}
+	for _, stream := range streams {
+		values := make([][2]string, len(stream.Entries))
+		for i, entry := range stream.Entries {
+			values[i] = [2]string{strconv.FormatInt(entry.Time.UnixNano(), 10), entry.Line}
+		}
+		push.Streams = append(push.Streams, jsonStream{Stream: stream.Labels, Values: values})
+	}
+	return push
+}
+
+// marshalPush encodes a logproto.PushRequest:
+//
+//  message PushRequest { repeated StreamAdapter streams = 1; }
+//  message StreamAdapter {
+//    string labels = 1;
+//    repeated EntryAdapter entries = 2;
+//  }
+//  message EntryAdapter {
+//    google.protobuf.Timestamp timestamp = 1;
+//    string line = 2;
+//  }
+func marshalPush(streams []*Stream) []byte {
+	var push []byte
+	for _, stream := range streams {
+		s := appendBytesField(nil, 1, []byte(labelString(stream.Labels)))
+		for _, entry := range stream.Entries {
+			var ts []byte
+			if sec := entry.Time.Unix(); sec != 0 {
+				ts = appendVarintField(ts, 1, uint64(sec))
+			}
+			if nsec := entry.Time.Nanosecond(); nsec != 0 {
+				ts = appendVarintField(ts, 2, uint64(nsec))
+			}
+			e := appendBytesField(nil, 1, ts)
+			e = appendBytesField(e, 2, []byte(entry.Line))
+			s = appendBytesField(s, 2, e)
+		}
+		push = appendBytesField(push, 1, s)
+	}
+	return push
+}
+
+func appendVarint(b []byte, u uint64) []byte {
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendVarintField(b []byte, field int, u uint64) []byte {
+	return appendVarint(appendVarint(b, uint64(field)<<3), u)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|2)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,279 @@
+package logrus_loki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// pushServer stands in for the Loki push API, keeping the requests it
+// receives.
+type pushServer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *pushServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newLogger(hook *logrus_http.Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+func TestJSONPush(t *testing.T) {
+	s := &pushServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	hook, err := NewHook(Config{
+		Config:       logrus_http.Config{URL: server.URL},
+		TenantID:     "walruses",
+		Labels:       []string{"app"},
+		StaticLabels: map[string]string{"env": "test"},
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.WithFields(logrus.Fields{"app": "api", "animal": "walrus"}).Info("A walrus appears")
+	logger.WithField("app", "api").Info("Another walrus")
+	logger.WithField("app", "db").Warn("The ice breaks")
+	assert.NoError(t, hook.Flush())
+
+	assert.Len(t, s.requests, 1)
+	assert.Equal(t, "application/json", s.requests[0].Header.Get("Content-Type"))
+	assert.Equal(t, "walruses", s.requests[0].Header.Get("X-Scope-OrgID"))
+
+	var push jsonPush
+	assert.NoError(t, json.Unmarshal(s.bodies[0], &push))
+	assert.Len(t, push.Streams, 2)
+	assert.Equal(t, map[string]string{"app": "api", "env": "test", "level": "info"}, push.Streams[0].Stream)
+	assert.Len(t, push.Streams[0].Values, 2)
+	assert.Equal(t, `level=info msg="A walrus appears" animal=walrus `, push.Streams[0].Values[0][1])
+	assert.Equal(t, map[string]string{"app": "db", "env": "test", "level": "warning"}, push.Streams[1].Stream)
+}
+
+func TestProtobufPush(t *testing.T) {
+	s := &pushServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	hook, err := NewHook(Config{
+		Config:   logrus_http.Config{URL: server.URL, Formatter: &logrus.JSONFormatter{}},
+		Labels:   []string{"app"},
+		Protobuf: true,
+	})
+	assert.NoError(t, err)
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	entry := logger.WithFields(logrus.Fields{"app": "api", "animal": "walrus"})
+	entry.Info("A walrus appears")
+	assert.NoError(t, hook.Flush())
+
+	assert.Equal(t, "application/x-protobuf", s.requests[0].Header.Get("Content-Type"))
+	push, err := snappyDecode(s.bodies[0])
+	assert.NoError(t, err)
+
+	stream := protoFields(t, protoFields(t, push)[1][0])
+	assert.Equal(t, `{app="api", level="info"}`, string(stream[1][0]))
+	e := protoFields(t, stream[2][0])
+	assert.Contains(t, string(e[2][0]), `{"animal":"walrus","level":"info","msg":"A walrus appears"`)
+	assert.NotContains(t, string(e[2][0]), `"app"`)
+	assert.NotEmpty(t, e[1][0])
+}
+
+func TestStreamsSortedByTime(t *testing.T) {
+	e := &Encoder{}
+	now := time.Now()
+	records := []logrus_http.Record{
+		{Entry: &logrus.Entry{Time: now.Add(time.Second), Level: logrus.InfoLevel}, Formatted: []byte("second\n")},
+		{Entry: &logrus.Entry{Time: now, Level: logrus.InfoLevel}, Formatted: []byte("first\n")},
+	}
+	streams := e.Streams(records)
+	assert.Len(t, streams, 1)
+	assert.Equal(t, "first", streams[0].Entries[0].Line)
+	assert.Equal(t, "second", streams[0].Entries[1].Line)
+}
+
+func TestLabelValueLimits(t *testing.T) {
+	e := &Encoder{
+		Labels:              []string{"app", "component"},
+		LabelValues:         map[string][]string{"app": {"api", "web"}},
+		MaxLabelValueLength: 5,
+	}
+	f := &lineFormatter{encoder: e, formatter: &logrus.JSONFormatter{}}
+	var records []logrus_http.Record
+	for _, fields := range []logrus.Fields{
+		{"app": "api", "component": "db"},
+		{"app": "worker", "component": "scheduler"},
+	} {
+		entry := &logrus.Entry{Data: fields, Level: logrus.InfoLevel}
+		formatted, err := f.Format(entry)
+		assert.NoError(t, err)
+		records = append(records, logrus_http.Record{Entry: entry, Formatted: formatted})
+	}
+
+	streams := e.Streams(records)
+	if assert.Len(t, streams, 2) {
+		assert.Equal(t, map[string]string{"app": "api", "component": "db", "level": "info"}, streams[0].Labels)
+		assert.NotContains(t, streams[0].Entries[0].Line, `"app"`)
+		assert.NotContains(t, streams[0].Entries[0].Line, `"component"`)
+		assert.Equal(t, map[string]string{"app": "other", "component": "sched", "level": "info"}, streams[1].Labels)
+		assert.Contains(t, streams[1].Entries[0].Line, `"app":"worker","component":"scheduler"`)
+	}
+
+	value, exact := (&Encoder{MaxLabelValueLength: 2}).labelValue("app", "é")
+	assert.Equal(t, "é", value)
+	assert.True(t, exact)
+	value, exact = (&Encoder{MaxLabelValueLength: 2}).labelValue("app", "aé")
+	assert.Equal(t, "a", value)
+	assert.False(t, exact)
+}
+
+func TestLabelValidation(t *testing.T) {
+	for _, labels := range [][]string{{"app-name"}, {"1app"}, {"__name__"}, {"level"}} {
+		_, err := NewHook(Config{Labels: labels})
+		assert.Error(t, err, "%v", labels)
+	}
+	_, err := NewHook(Config{StaticLabels: map[string]string{"bad label": "x"}})
+	assert.Error(t, err)
+}
+
+func TestLabelString(t *testing.T) {
+	assert.Equal(t, `{a="1", b="quote \" and\nnewline"}`, labelString(map[string]string{"b": "quote \" and\nnewline", "a": "1"}))
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("walrus"),
+		bytes.Repeat([]byte("walrus "), 20000),
+		[]byte(strings.Repeat("abcdefghijklmnopqrstuvwxyz", 3000) + "tail"),
+	}
+	for _, input := range inputs {
+		encoded := snappyEncode(nil, input)
+		decoded, err := snappyDecode(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, len(input), len(decoded))
+		assert.True(t, bytes.Equal(input, decoded))
+	}
+	assert.True(t, len(snappyEncode(nil, inputs[2])) < len(inputs[2])/10)
+}
+
+func TestSnappyReferenceVector(t *testing.T) {
+	// Encoded by github.com/golang/snappy, which also decodes the output of
+	// snappyEncode for this input. It checks snappyDecode against the
+	// reference implementation.
+	input := `{"streams":[{"stream":{"app":"api","level":"info"},"values":[["1","A walrus appears, A walrus appears, A walrus appears"]]}]}`
+	reference, _ := hex.DecodeString("7d2c7b2273747265616d73223a5b110cf040223a7b22617070223a22617069222c226c6576656c223a22696e666f227d2c2276616c756573223a5b5b2231222c22412077616c72757320617070656172732c2086120014225d5d7d5d7d")
+
+	decoded, err := snappyDecode(reference)
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(decoded))
+
+	decoded, err = snappyDecode(snappyEncode(nil, []byte(input)))
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(decoded))
+}
+
+// snappyDecode decodes the Snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, i := binary.Uvarint(src)
+	if i <= 0 {
+		return nil, errors.New("bad length")
+	}
+	dst := make([]byte, 0, n)
+	for src = src[i:]; len(src) > 0; {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				size := length - 59
+				length = 0
+				for j := size - 1; j >= 0; j-- {
+					length = length<<8 | int(src[j])
+				}
+				src = src[size:]
+			}
+			length++
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		default:
+			var length, offset int
+			switch tag & 3 {
+			case 1:
+				length = int(tag>>2&7) + 4
+				offset = int(tag&0xe0)<<3 | int(src[1])
+				src = src[2:]
+			case 2:
+				length = int(tag>>2) + 1
+				offset = int(binary.LittleEndian.Uint16(src[1:]))
+				src = src[3:]
+			case 3:
+				length = int(tag>>2) + 1
+				offset = int(binary.LittleEndian.Uint32(src[1:]))
+				src = src[5:]
+			}
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("bad offset")
+			}
+			for j := 0; j < length; j++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("bad decoded length")
+	}
+	return dst, nil
+}
+
+// protoFields decodes the varint and length delimited fields of a protobuf
+// message, by field number.
+func protoFields(t *testing.T, b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			fields[int(key>>3)] = append(fields[int(key>>3)], b[:n])
+			b = b[n:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[int(key>>3)] = append(fields[int(key>>3)], b[:length])
+			b = b[length:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,82 @@
+package logrus_loki
+
+import "encoding/binary"
+
+// snappyEncode appends the Snappy block format encoding of src to dst, as
+// described in https://github.com/google/snappy/blob/master/format_description.txt.
+// It's a simple greedy compressor finding matches of at least four bytes
+// within 64KiB blocks.
+func snappyEncode(dst, src []byte) []byte {
+	dst = appendVarint(dst, uint64(len(src)))
+	for len(src) > 0 {
+		block := src
+		if len(block) > 1<<16 {
+			block = block[:1<<16]
+		}
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+	return dst
+}
+
+const snappyTableBits = 14
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func snappyEncodeBlock(dst, src []byte) []byte {
+	// Positions are stored plus one, so zero means empty.
+	var table [1 << snappyTableBits]int32
+	literal := 0
+	for s := 0; s+4 <= len(src); {
+		u := binary.LittleEndian.Uint32(src[s:])
+		h := snappyHash(u)
+		candidate := int(table[h]) - 1
+		table[h] = int32(s + 1)
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != u {
+			s++
+			continue
+		}
+
+		dst = snappyLiteral(dst, src[literal:s])
+		length := 4
+		for s+length < len(src) && src[candidate+length] == src[s+length] {
+			length++
+		}
+		dst = snappyCopy(dst, s-candidate, length)
+		s += length
+		literal = s
+	}
+	return snappyLiteral(dst, src[literal:])
+}
+
+func snappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	switch n := uint32(len(lit) - 1); {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	default:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(dst, lit...)
+}
+
+// snappyCopy emits copies with a two byte offset, each at most 64 bytes long.
+func snappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+		dst = append(dst, byte(n-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
@@ -0,0 +1,388 @@
+package logrus_network
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/internal/batch"
+)
+
+// ErrQueueFull is returned by Fire when entries are logged faster than they
+// can be written and the queue has no room left.
+var ErrQueueFull = errors.New("network: write queue is full, entry dropped")
+
+// ErrClosed is returned by Fire and Flush once the hook has been closed.
+var ErrClosed = errors.New("network: hook is closed")
+
+// Framing selects how entries are delimited on stream connections.
+// Datagram connections always send one entry per datagram.
+type Framing int
+
+const (
+	// NewlineFraming ends every entry with a single newline, as most line
+	// based receivers expect.
+	NewlineFraming Framing = iota
+	// OctetCounting precedes every entry with its length in bytes and a
+	// space, as described in RFC 6587. Entries may then contain newlines.
+	OctetCounting
+)
+
+// Config configures a Hook.
+type Config struct {
+	// Network is "tcp", "udp", "unix" or "unixgram". Defaults to "tcp".
+	Network string
+	// Address of the endpoint, a host:port pair or a socket path.
+	Address string
+	// TLSConfig, when set, secures "tcp" connections with TLS.
+	TLSConfig *tls.Config
+
+	// Formatter formats the entries written to the endpoint. Defaults to
+	// `logrus.JSONFormatter`.
+	Formatter logrus.Formatter
+	Framing   Framing
+
+	// BatchSize is the maximum number of entries written at once. Defaults
+	// to 100.
+	BatchSize int
+	// FlushInterval is the longest an entry waits for its batch to fill up
+	// before being written anyway. Defaults to one second.
+	FlushInterval time.Duration
+	// QueueSize is the number of entries buffered while waiting to be
+	// written. Defaults to 4096.
+	QueueSize int
+
+	// DialTimeout and WriteTimeout bound connecting and writing a batch.
+	// Both default to ten seconds.
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// RetryBufferSize is the number of bytes of entries kept while the
+	// endpoint is unreachable, to be written once the connection is back.
+	// The oldest entries are dropped first. Defaults to 1MiB.
+	RetryBufferSize int
+	// RetryBackoff is the delay before reconnecting after a failure, doubled
+	// on each following failure up to MaxBackoff. Default to 500ms and 30
+	// seconds.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Levels the hook fires on. Defaults to all levels.
+	Levels []logrus.Level
+}
+
+// Stats are counters describing the activity of a Hook since its creation.
+type Stats struct {
+	// Entries successfully formatted and queued by Fire.
+	Fired uint64
+	// Entries written to the endpoint.
+	Sent uint64
+	// Entries dropped because the queue or the retry buffer was full, or
+	// because they couldn't be formatted.
+	Dropped uint64
+	// Failed connection attempts and writes.
+	Errors uint64
+	// Connections established, the first one included.
+	Connects uint64
+	// Entries waiting in the retry buffer.
+	Buffered int
+
+	// LastError is the last error met, and LastErrorTime when it was.
+	LastError     error
+	LastErrorTime time.Time
+}
+
+// Hook writes formatted entries to a TCP, UDP or unix socket endpoint. Entries
+// are formatted when fired and written in batches by a background goroutine,
+// so Fire never waits on the network. When the endpoint is unreachable,
+// entries are kept in a bounded retry buffer while the hook reconnects with
+// exponential backoff. Fire only returns errors it can see right away: a
+// formatter error, ErrQueueFull when the queue is full, or ErrClosed after
+// Close. Delivery failures happen later and are counted in Stats instead.
+// Call Flush to wait until everything fired so far has been written, and
+// Close when done with the hook. Until then, pending entries are flushed
+// on exit, see logrus.RegisterExitFlusher.
+//
+// Stream connections can't tell what was received before they broke, so
+// a batch is written again in full after a failed write, and the endpoint
+// may receive some entries twice.
+type Hook struct {
+	config   Config
+	datagram bool
+	conn     net.Conn
+
+	// Entries in the retry buffer, and their size in bytes. Only used by the
+	// background goroutine.
+	pending     [][]byte
+	pendingSize int
+	retryAt     time.Time
+	backoff     time.Duration
+
+	fired, sent, dropped, errors, connects uint64
+
+	statsMu       sync.Mutex
+	buffered      int
+	lastError     error
+	lastErrorTime time.Time
+
+	batcher *batch.Batcher
+}
+
+// NewHook creates a hook writing to the endpoint described by config and
+// starts its background writer. The connection is established on the first
+// batch. Add it to a logger with `log.Hooks.Add(hook)`.
+func NewHook(config Config) *Hook {
+	if config.Network == "" {
+		config.Network = "tcp"
+	}
+	if config.Formatter == nil {
+		config.Formatter = &logrus.JSONFormatter{}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 4096
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 10 * time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 10 * time.Second
+	}
+	if config.RetryBufferSize <= 0 {
+		config.RetryBufferSize = 1 << 20
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.Levels == nil {
+		config.Levels = logrus.AllLevels
+	}
+
+	hook := &Hook{
+		config:   config,
+		datagram: config.Network == "udp" || config.Network == "unixgram",
+	}
+	hook.batcher = batch.New(batch.Config{
+		Name:         "network hook",
+		Size:         config.BatchSize,
+		Interval:     config.FlushInterval,
+		QueueSize:    config.QueueSize,
+		Send:         hook.send,
+		Stop:         hook.stop,
+		ErrQueueFull: ErrQueueFull,
+		ErrClosed:    ErrClosed,
+	})
+
+	return hook
+}
+
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.config.Levels
+}
+
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	serialized, err := hook.config.Formatter.Format(entry)
+	if err != nil {
+		atomic.AddUint64(&hook.dropped, 1)
+		hook.setError(err)
+		return err
+	}
+	frame := hook.frame(serialized)
+	switch err := hook.batcher.Add(frame, len(frame)); err {
+	case nil:
+		atomic.AddUint64(&hook.fired, 1)
+		return nil
+	case ErrQueueFull:
+		atomic.AddUint64(&hook.dropped, 1)
+		hook.setError(err)
+		return err
+	default:
+		return err
+	}
+}
+
+// frame copies a formatted entry, as the formatter may reuse its buffer, and
+// delimits it.
+func (hook *Hook) frame(serialized []byte) []byte {
+	if hook.datagram {
+		return append([]byte(nil), serialized...)
+	}
+	if hook.config.Framing == OctetCounting {
+		b := strconv.AppendInt(make([]byte, 0, len(serialized)+8), int64(len(serialized)), 10)
+		return append(append(b, ' '), serialized...)
+	}
+	b := append(make([]byte, 0, len(serialized)+1), bytes.TrimRight(serialized, "\n")...)
+	return append(b, '\n')
+}
+
+// Stats returns a snapshot of the counters of the hook.
+func (hook *Hook) Stats() Stats {
+	hook.statsMu.Lock()
+	defer hook.statsMu.Unlock()
+	return Stats{
+		Fired:         atomic.LoadUint64(&hook.fired),
+		Sent:          atomic.LoadUint64(&hook.sent),
+		Dropped:       atomic.LoadUint64(&hook.dropped),
+		Errors:        atomic.LoadUint64(&hook.errors),
+		Connects:      atomic.LoadUint64(&hook.connects),
+		Buffered:      hook.buffered,
+		LastError:     hook.lastError,
+		LastErrorTime: hook.lastErrorTime,
+	}
+}
+
+func (hook *Hook) setError(err error) {
+	hook.statsMu.Lock()
+	hook.lastError = err
+	hook.lastErrorTime = time.Now()
+	hook.statsMu.Unlock()
+}
+
+// Flush writes all the entries fired so far, reconnecting right away if
+// needed, and returns an error if some are still waiting in the retry buffer.
+func (hook *Hook) Flush() error {
+	return hook.batcher.Flush()
+}
+
+// Close flushes the pending entries, stops the background writer and closes
+// the connection. Entries left in the retry buffer are dropped.
+func (hook *Hook) Close() error {
+	return hook.batcher.Close()
+}
+
+// send adds the frames queued by Fire to the retry buffer and writes it,
+// from the goroutine of the batcher. Outside of flushes, it waits for the
+// backoff after a failure, and leaves the errors to Stats.
+func (hook *Hook) send(items []interface{}, flushing bool) error {
+	frames := make([][]byte, len(items))
+	for i, item := range items {
+		frames[i] = item.([]byte)
+	}
+	hook.buffer(frames)
+	if len(hook.pending) == 0 || (!flushing && time.Now().Before(hook.retryAt)) {
+		return nil
+	}
+	if err := hook.write(); err != nil && flushing {
+		return err
+	}
+	return nil
+}
+
+// stop closes the connection and drops the entries left in the retry
+// buffer, once the batcher stopped.
+func (hook *Hook) stop() {
+	if hook.conn != nil {
+		hook.conn.Close()
+	}
+	atomic.AddUint64(&hook.dropped, uint64(len(hook.pending)))
+}
+
+// buffer adds frames to the retry buffer, dropping the oldest ones beyond
+// its size.
+func (hook *Hook) buffer(frames [][]byte) {
+	for _, frame := range frames {
+		hook.pending = append(hook.pending, frame)
+		hook.pendingSize += len(frame)
+	}
+	for len(hook.pending) > 1 && hook.pendingSize > hook.config.RetryBufferSize {
+		hook.pendingSize -= len(hook.pending[0])
+		hook.pending[0] = nil
+		hook.pending = hook.pending[1:]
+		atomic.AddUint64(&hook.dropped, 1)
+	}
+	hook.updateBuffered()
+}
+
+func (hook *Hook) updateBuffered() {
+	hook.statsMu.Lock()
+	hook.buffered = len(hook.pending)
+	hook.statsMu.Unlock()
+}
+
+// write sends the retry buffer, BatchSize entries at a time. On failure, the
+// connection is dropped and the next attempt delayed.
+func (hook *Hook) write() error {
+	for len(hook.pending) > 0 {
+		n := len(hook.pending)
+		if n > hook.config.BatchSize {
+			n = hook.config.BatchSize
+		}
+		sent, err := hook.writeFrames(hook.pending[:n])
+		for _, frame := range hook.pending[:sent] {
+			hook.pendingSize -= len(frame)
+		}
+		hook.pending = hook.pending[sent:]
+		atomic.AddUint64(&hook.sent, uint64(sent))
+
+		if err != nil {
+			atomic.AddUint64(&hook.errors, 1)
+			hook.setError(err)
+			if hook.conn != nil {
+				hook.conn.Close()
+				hook.conn = nil
+			}
+			if hook.backoff == 0 {
+				hook.backoff = hook.config.RetryBackoff
+			} else if hook.backoff *= 2; hook.backoff > hook.config.MaxBackoff {
+				hook.backoff = hook.config.MaxBackoff
+			}
+			hook.retryAt = time.Now().Add(hook.backoff)
+			hook.updateBuffered()
+			return err
+		}
+	}
+	hook.backoff = 0
+	hook.pending = nil
+	hook.updateBuffered()
+	return nil
+}
+
+// writeFrames writes frames over the connection, establishing it first if
+// needed, and returns how many were written.
+func (hook *Hook) writeFrames(frames [][]byte) (int, error) {
+	if hook.conn == nil {
+		conn, err := hook.dial()
+		if err != nil {
+			return 0, err
+		}
+		hook.conn = conn
+		atomic.AddUint64(&hook.connects, 1)
+	}
+
+	hook.conn.SetWriteDeadline(time.Now().Add(hook.config.WriteTimeout))
+	if hook.datagram {
+		for i, frame := range frames {
+			if _, err := hook.conn.Write(frame); err != nil {
+				return i, err
+			}
+		}
+		return len(frames), nil
+	}
+
+	if _, err := hook.conn.Write(bytes.Join(frames, nil)); err != nil {
+		return 0, err
+	}
+	return len(frames), nil
+}
+
+func (hook *Hook) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: hook.config.DialTimeout}
+	if hook.config.TLSConfig != nil && hook.config.Network == "tcp" {
+		return tls.DialWithDialer(dialer, "tcp", hook.config.Address, hook.config.TLSConfig)
+	}
+	return dialer.Dial(hook.config.Network, hook.config.Address)
+}
@@ -0,0 +1,231 @@
+package logrus_network
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+// acceptLines sends each line read from the connections accepted by
+// listener over the returned channel.
+func acceptLines(listener net.Listener) chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func next(t *testing.T, lines chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received")
+		return ""
+	}
+}
+
+func TestNewlineFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := acceptLines(listener)
+
+	hook := NewHook(Config{
+		Address:   listener.Addr().String(),
+		Formatter: &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.WithField("animal", "walrus").Info("A walrus appears")
+	logger.Warn("The ice breaks")
+	assert.NoError(t, hook.Flush())
+
+	assert.Equal(t, "level=info msg=\"A walrus appears\" animal=walrus \n", next(t, lines))
+	assert.Equal(t, "level=warning msg=\"The ice breaks\" \n", next(t, lines))
+
+	stats := hook.Stats()
+	assert.Equal(t, uint64(2), stats.Fired)
+	assert.Equal(t, uint64(2), stats.Sent)
+	assert.Equal(t, uint64(1), stats.Connects)
+	assert.Equal(t, uint64(0), stats.Errors)
+}
+
+func TestOctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hook := NewHook(Config{
+		Address:   listener.Addr().String(),
+		Framing:   OctetCounting,
+		Formatter: &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	entry := logger.WithField("animal", "walrus")
+	entry.Level = logrus.InfoLevel
+	entry.Message = "first\nsecond"
+	frame, _ := hook.config.Formatter.Format(entry)
+	entry.Info("first\nsecond")
+	assert.NoError(t, hook.Flush())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, len(frame)+3)
+	_, err = io.ReadFull(conn, b)
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(len(frame))+" "+string(frame), string(b))
+}
+
+func TestUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hook := NewHook(Config{
+		Network:   "udp",
+		Address:   conn.LocalAddr().String(),
+		Formatter: &logrus.JSONFormatter{DisableTimestamp: true},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("A walrus appears")
+	logger.Info("Another walrus")
+	assert.NoError(t, hook.Flush())
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	for _, msg := range []string{"A walrus appears", "Another walrus"} {
+		n, _, err := conn.ReadFrom(b)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b[:n]), msg)
+		assert.Equal(t, 1, strings.Count(string(b[:n]), "\n"))
+	}
+}
+
+func TestTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.StartTLS()
+	certificates := server.TLS.Certificates
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := acceptLines(listener)
+
+	hook := NewHook(Config{
+		Address:   listener.Addr().String(),
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("A secret walrus")
+	assert.NoError(t, hook.Flush())
+	assert.Contains(t, next(t, lines), "A secret walrus")
+}
+
+func TestRetryBuffer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	hook := NewHook(Config{
+		Address:         address,
+		Formatter:       &logrus.TextFormatter{DisableColors: true, DisableTimestamp: true},
+		RetryBufferSize: 50,
+		RetryBackoff:    time.Millisecond,
+	})
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("third")
+	assert.Error(t, hook.Flush())
+
+	stats := hook.Stats()
+	assert.Equal(t, uint64(3), stats.Fired)
+	assert.Equal(t, uint64(0), stats.Sent)
+	assert.Equal(t, uint64(1), stats.Dropped)
+	assert.Equal(t, 2, stats.Buffered)
+	assert.Equal(t, uint64(1), stats.Errors)
+	assert.Error(t, stats.LastError)
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := acceptLines(listener)
+
+	assert.NoError(t, hook.Flush())
+	assert.Contains(t, next(t, lines), "second")
+	assert.Contains(t, next(t, lines), "third")
+
+	stats = hook.Stats()
+	assert.Equal(t, uint64(2), stats.Sent)
+	assert.Equal(t, 0, stats.Buffered)
+}
+
+func TestClosed(t *testing.T) {
+	hook := NewHook(Config{Address: "127.0.0.1:1"})
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, ErrClosed, hook.Fire(logrus.NewEntry(logrus.New())))
+	assert.Equal(t, ErrClosed, hook.Flush())
+}
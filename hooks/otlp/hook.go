@@ -0,0 +1,222 @@
+package logrus_otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/internal/batch"
+	"github.com/sirupsen/logrus/hooks/internal/httpretry"
+)
+
+// DefaultEndpoint is the OTLP/HTTP logs endpoint of a collector running with
+// its default configuration on the local host.
+const DefaultEndpoint = "http://localhost:4318/v1/logs"
+
+// ErrQueueFull is returned by Fire when entries are logged faster than they
+// can be exported and the queue has no room left.
+var ErrQueueFull = errors.New("otlp: export queue is full, entry dropped")
+
+// ErrClosed is returned by Fire and Flush once the hook has been closed.
+var ErrClosed = errors.New("otlp: hook is closed")
+
+// Config configures a Hook. The zero value exports to DefaultEndpoint.
+type Config struct {
+	// Endpoint is the full URL log records are POSTed to.
+	Endpoint string
+	// Headers are added to every export request, e.g. for authentication.
+	Headers map[string]string
+
+	// Resource attributes describing the entity producing the logs, such as
+	// `service.name`.
+	Resource map[string]interface{}
+	// Name and version of the instrumentation scope. The scope name defaults
+	// to "github.com/sirupsen/logrus".
+	ScopeName    string
+	ScopeVersion string
+
+	// BatchSize is the maximum number of log records per export request.
+	// Defaults to 512.
+	BatchSize int
+	// FlushInterval is the longest a log record waits for its batch to fill up
+	// before being exported anyway. Defaults to one second.
+	FlushInterval time.Duration
+	// QueueSize is the number of log records buffered while waiting for export.
+	// Defaults to 2048.
+	QueueSize int
+
+	// MaxRetries is the number of times a failed export is retried before its
+	// batch is dropped. Defaults to 5, a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// following attempt up to MaxBackoff. Defaults to 100ms and 30 seconds.
+	// A longer Retry-After from the collector wins, up to MaxBackoff.
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// Client is used for export requests. Defaults to a client with a ten
+	// second timeout.
+	Client *http.Client
+
+	// Levels the hook fires on. Defaults to all levels.
+	Levels []logrus.Level
+}
+
+// Hook exports entries to an OpenTelemetry collector as OTLP/JSON over HTTP.
+// Entries are converted when fired and exported in batches by a background
+// goroutine, so Fire never waits on the network. Call Flush to wait until
+// everything fired so far has been exported, and Close when done with the
+// hook. Until then, pending entries are flushed on exit, see
+// logrus.RegisterExitFlusher.
+type Hook struct {
+	config  Config
+	scope   Scope
+	batcher *batch.Batcher
+}
+
+// NewHook creates a hook exporting to the collector described by config and
+// starts its background exporter. Add it to a logger with
+// `log.Hooks.Add(hook)`.
+func NewHook(config Config) *Hook {
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultEndpoint
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2048
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.Levels == nil {
+		config.Levels = logrus.AllLevels
+	}
+
+	hook := &Hook{
+		config: config,
+		scope:  newScope(config.ScopeName, config.ScopeVersion),
+	}
+	hook.batcher = batch.New(batch.Config{
+		Name:         "otlp hook",
+		Size:         config.BatchSize,
+		Interval:     config.FlushInterval,
+		QueueSize:    config.QueueSize,
+		Send:         hook.send,
+		ErrQueueFull: ErrQueueFull,
+		ErrClosed:    ErrClosed,
+	})
+
+	return hook
+}
+
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.config.Levels
+}
+
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	return hook.batcher.Add(NewLogRecord(entry, time.Now()), 0)
+}
+
+// Flush exports all the entries fired so far and returns the error of the
+// last failed export, if any.
+func (hook *Hook) Flush() error {
+	return hook.batcher.Flush()
+}
+
+// Close flushes the pending entries and stops the background exporter.
+func (hook *Hook) Close() error {
+	return hook.batcher.Close()
+}
+
+// send exports the records queued by Fire, from the goroutine of the
+// batcher.
+func (hook *Hook) send(items []interface{}, flushing bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+	records := make([]LogRecord, len(items))
+	for i, item := range items {
+		records[i] = item.(LogRecord)
+	}
+	return hook.export(records)
+}
+
+// export sends records, retrying with exponential backoff on network errors
+// and on the status codes the OTLP specification marks as retryable.
+func (hook *Hook) export(records []LogRecord) error {
+	body, err := json.Marshal(newRequest(hook.config.Resource, hook.scope, records))
+	if err != nil {
+		return fmt.Errorf("otlp: failed to marshal log records, %v", err)
+	}
+
+	backoff := hook.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := hook.post(body)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= hook.config.MaxRetries {
+			return err
+		}
+		if retryAfter < backoff {
+			retryAfter = backoff
+		}
+		if retryAfter > hook.config.MaxBackoff {
+			retryAfter = hook.config.MaxBackoff
+		}
+		time.Sleep(retryAfter)
+		if backoff *= 2; backoff > hook.config.MaxBackoff {
+			backoff = hook.config.MaxBackoff
+		}
+	}
+}
+
+// post sends one export request. A negative delay means the request must not
+// be retried; otherwise it is the minimum delay requested by the collector.
+func (hook *Hook) post(body []byte) (time.Duration, error) {
+	req, err := http.NewRequest("POST", hook.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hook.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := hook.config.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("otlp: export failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return httpretry.After(resp.Header.Get("Retry-After")), err
+	}
+	return -1, err
+}
@@ -0,0 +1,321 @@
+// Package logrus_otlp converts logrus entries into the OpenTelemetry log data
+// model and exports them as OTLP/JSON, either line by line through Formatter
+// or in batches to an OpenTelemetry collector over HTTP through Hook.
+package logrus_otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Field keys the trace context of a log record is read from. Fields found
+// under these keys are moved out of the attributes and into the `traceId`,
+// `spanId` and `flags` of the log record. Trace and span IDs are expected as
+// lowercase hex strings, as in a W3C `traceparent` header. They default to
+// the keys used by `logrus.TraceHook`. Entries without these fields use the
+// trace context stored in their context by `logrus.ContextWithTrace`, if any.
+var (
+	TraceIDKey    = logrus.FieldKeyTraceID
+	SpanIDKey     = logrus.FieldKeySpanID
+	TraceFlagsKey = logrus.FieldKeyTraceFlags
+)
+
+// Severity numbers of the OpenTelemetry log data model which logrus levels
+// are mapped to.
+const (
+	SeverityDebug = 5
+	SeverityInfo  = 9
+	SeverityWarn  = 13
+	SeverityError = 17
+	SeverityFatal = 21
+	// Panic is more severe than Fatal in logrus, so it is mapped to the
+	// highest number of the FATAL range.
+	SeverityPanic = 24
+)
+
+// SeverityNumber maps a logrus level to an OpenTelemetry severity number.
+func SeverityNumber(level logrus.Level) int {
+	switch level {
+	case logrus.DebugLevel:
+		return SeverityDebug
+	case logrus.InfoLevel:
+		return SeverityInfo
+	case logrus.WarnLevel:
+		return SeverityWarn
+	case logrus.ErrorLevel:
+		return SeverityError
+	case logrus.FatalLevel:
+		return SeverityFatal
+	case logrus.PanicLevel:
+		return SeverityPanic
+	}
+	return 0
+}
+
+// ExportLogsServiceRequest is the OTLP/JSON payload accepted by the
+// `/v1/logs` endpoint of a collector.
+type ExportLogsServiceRequest struct {
+	ResourceLogs []ResourceLogs `json:"resourceLogs"`
+}
+
+type ResourceLogs struct {
+	Resource  Resource    `json:"resource"`
+	ScopeLogs []ScopeLogs `json:"scopeLogs"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeLogs struct {
+	Scope      Scope       `json:"scope"`
+	LogRecords []LogRecord `json:"logRecords"`
+}
+
+type Scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// LogRecord is a single entry in the OpenTelemetry log data model. 64 bit
+// integers are encoded as strings, as mandated by the proto3 JSON mapping.
+type LogRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 AnyValue   `json:"body"`
+	Attributes           []KeyValue `json:"attributes,omitempty"`
+	Flags                uint32     `json:"flags,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds exactly one of its fields.
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *string       `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+// NewLogRecord converts an entry into a log record. observed is the time the
+// entry was picked up for export.
+func NewLogRecord(entry *logrus.Entry, observed time.Time) LogRecord {
+	record := LogRecord{
+		TimeUnixNano:         unixNano(entry.Time),
+		ObservedTimeUnixNano: unixNano(observed),
+		SeverityNumber:       SeverityNumber(entry.Level),
+		SeverityText:         entry.Level.String(),
+		Body:                 stringValue(entry.Message),
+	}
+
+	traceID, traceOK := hexField(entry.Data, TraceIDKey, 16)
+	spanID, spanOK := hexField(entry.Data, SpanIDKey, 8)
+	flags, flagsOK := flagsField(entry.Data, TraceFlagsKey)
+	if traceOK {
+		record.TraceID = traceID
+	}
+	if spanOK {
+		record.SpanID = spanID
+	}
+	if flagsOK {
+		record.Flags = flags
+	}
+	if tc, ok := logrus.TraceFromContext(entry.Context); ok && !traceOK {
+		record.TraceID = hex.EncodeToString(tc.TraceID[:])
+		record.SpanID = hex.EncodeToString(tc.SpanID[:])
+		record.Flags = uint32(tc.Flags)
+	}
+
+	for k, v := range entry.Data {
+		if (traceOK && k == TraceIDKey) || (spanOK && k == SpanIDKey) || (flagsOK && k == TraceFlagsKey) {
+			continue
+		}
+		record.Attributes = append(record.Attributes, KeyValue{Key: k, Value: NewAnyValue(v)})
+	}
+	sortKeyValues(record.Attributes)
+
+	return record
+}
+
+// NewAnyValue converts a field value into an AnyValue. Values without a
+// matching OTLP type are rendered as strings.
+func NewAnyValue(v interface{}) AnyValue {
+	switch v := v.(type) {
+	case nil:
+		return AnyValue{}
+	case string:
+		return stringValue(v)
+	case bool:
+		return AnyValue{BoolValue: &v}
+	case []byte:
+		return AnyValue{BytesValue: v}
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := strconv.FormatInt(rv.Int(), 10)
+		return AnyValue{IntValue: &s}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s := strconv.FormatUint(rv.Uint(), 10)
+		return AnyValue{IntValue: &s}
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return AnyValue{DoubleValue: &f}
+	case reflect.Slice, reflect.Array:
+		values := make([]AnyValue, rv.Len())
+		for i := range values {
+			values[i] = NewAnyValue(rv.Index(i).Interface())
+		}
+		return AnyValue{ArrayValue: &ArrayValue{Values: values}}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		values := make([]KeyValue, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			values = append(values, KeyValue{Key: key.String(), Value: NewAnyValue(rv.MapIndex(key).Interface())})
+		}
+		sortKeyValues(values)
+		return AnyValue{KvlistValue: &KeyValueList{Values: values}}
+	}
+
+	return stringValue(fmt.Sprint(v))
+}
+
+// Formatter renders each entry as a complete OTLP/JSON
+// ExportLogsServiceRequest on a single line, the format read by the
+// collector's file based receivers.
+type Formatter struct {
+	// Resource attributes describing the entity producing the logs, such as
+	// `service.name`.
+	Resource map[string]interface{}
+
+	// Name and version of the instrumentation scope. The scope name defaults
+	// to "github.com/sirupsen/logrus".
+	ScopeName    string
+	ScopeVersion string
+}
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	request := newRequest(f.Resource, f.scope(), []LogRecord{NewLogRecord(entry, time.Now())})
+
+	serialized, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal fields to JSON, %v", err)
+	}
+	return append(serialized, '\n'), nil
+}
+
+func (f *Formatter) scope() Scope {
+	return newScope(f.ScopeName, f.ScopeVersion)
+}
+
+func newScope(name, version string) Scope {
+	if name == "" {
+		name = "github.com/sirupsen/logrus"
+	}
+	return Scope{Name: name, Version: version}
+}
+
+func newRequest(resource map[string]interface{}, scope Scope, records []LogRecord) ExportLogsServiceRequest {
+	attributes := make([]KeyValue, 0, len(resource))
+	for k, v := range resource {
+		attributes = append(attributes, KeyValue{Key: k, Value: NewAnyValue(v)})
+	}
+	sortKeyValues(attributes)
+
+	return ExportLogsServiceRequest{
+		ResourceLogs: []ResourceLogs{{
+			Resource: Resource{Attributes: attributes},
+			ScopeLogs: []ScopeLogs{{
+				Scope:      scope,
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+func stringValue(s string) AnyValue {
+	return AnyValue{StringValue: &s}
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// hexField returns the field under key if it is a valid, non zero, hex
+// encoded ID of the given size in bytes.
+func hexField(data logrus.Fields, key string, size int) (string, bool) {
+	s, ok := data[key].(string)
+	if !ok || len(s) != 2*size {
+		return "", false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", false
+	}
+	for _, c := range b {
+		if c != 0 {
+			return hex.EncodeToString(b), true
+		}
+	}
+	return "", false
+}
+
+// flagsField accepts trace flags either as an integer or as the two hex digit
+// string used in a `traceparent` header.
+func flagsField(data logrus.Fields, key string) (uint32, bool) {
+	switch v := data[key].(type) {
+	case string:
+		n, err := strconv.ParseUint(v, 16, 8)
+		return uint32(n), err == nil
+	case int:
+		return uint32(v), v >= 0 && v <= 0xff
+	case uint8:
+		return uint32(v), true
+	}
+	return 0, false
+}
+
+type byKey []KeyValue
+
+func (kvs byKey) Len() int           { return len(kvs) }
+func (kvs byKey) Less(i, j int) bool { return kvs[i].Key < kvs[j].Key }
+func (kvs byKey) Swap(i, j int)      { kvs[i], kvs[j] = kvs[j], kvs[i] }
+
+func sortKeyValues(kvs []KeyValue) {
+	sort.Sort(byKey(kvs))
+}
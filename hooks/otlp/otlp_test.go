@@ -0,0 +1,288 @@
+package logrus_otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogRecord(t *testing.T) {
+	entry := logrus.WithFields(logrus.Fields{
+		"animal":      "walrus",
+		"count":       3,
+		"ratio":       0.5,
+		"ok":          true,
+		"err":         errors.New("wild walrus"),
+		"trace_id":    "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":     "00f067aa0ba902b7",
+		"trace_flags": "01",
+	})
+	entry.Time = time.Unix(1500000000, 42)
+	entry.Level = logrus.WarnLevel
+	entry.Message = "A walrus appears"
+
+	record := NewLogRecord(entry, time.Unix(1500000001, 0))
+
+	assert.Equal(t, "1500000000000000042", record.TimeUnixNano)
+	assert.Equal(t, "1500000001000000000", record.ObservedTimeUnixNano)
+	assert.Equal(t, SeverityWarn, record.SeverityNumber)
+	assert.Equal(t, "warning", record.SeverityText)
+	assert.Equal(t, "A walrus appears", *record.Body.StringValue)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", record.SpanID)
+	assert.Equal(t, uint32(1), record.Flags)
+
+	keys := make([]string, len(record.Attributes))
+	for i, kv := range record.Attributes {
+		keys[i] = kv.Key
+	}
+	assert.Equal(t, []string{"animal", "count", "err", "ok", "ratio"}, keys)
+	assert.Equal(t, "walrus", *record.Attributes[0].Value.StringValue)
+	assert.Equal(t, "3", *record.Attributes[1].Value.IntValue)
+	assert.Equal(t, "wild walrus", *record.Attributes[2].Value.StringValue)
+	assert.Equal(t, true, *record.Attributes[3].Value.BoolValue)
+	assert.Equal(t, 0.5, *record.Attributes[4].Value.DoubleValue)
+}
+
+func TestNewLogRecordInvalidTraceContext(t *testing.T) {
+	entry := logrus.WithFields(logrus.Fields{
+		"trace_id": "00000000000000000000000000000000",
+		"span_id":  "not hex",
+	})
+
+	record := NewLogRecord(entry, time.Now())
+	assert.Empty(t, record.TraceID)
+	assert.Empty(t, record.SpanID)
+	assert.Len(t, record.Attributes, 2)
+}
+
+func TestNewLogRecordTraceFromContext(t *testing.T) {
+	tc, err := logrus.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(t, err)
+	entry := logrus.WithContext(logrus.ContextWithTrace(context.Background(), tc))
+
+	record := NewLogRecord(entry, time.Now())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", record.SpanID)
+	assert.Equal(t, uint32(1), record.Flags)
+	assert.Empty(t, record.Attributes)
+}
+
+func TestSeverityNumber(t *testing.T) {
+	assert.Equal(t, SeverityDebug, SeverityNumber(logrus.DebugLevel))
+	assert.Equal(t, SeverityInfo, SeverityNumber(logrus.InfoLevel))
+	assert.Equal(t, SeverityError, SeverityNumber(logrus.ErrorLevel))
+	assert.Equal(t, SeverityFatal, SeverityNumber(logrus.FatalLevel))
+	assert.Equal(t, SeverityPanic, SeverityNumber(logrus.PanicLevel))
+}
+
+func TestFormatter(t *testing.T) {
+	f := &Formatter{Resource: map[string]interface{}{"service.name": "zoo"}}
+
+	b, err := f.Format(logrus.WithField("animal", "walrus"))
+	assert.NoError(t, err)
+
+	var request ExportLogsServiceRequest
+	assert.NoError(t, json.Unmarshal(b, &request))
+	assert.Len(t, request.ResourceLogs, 1)
+	assert.Equal(t, "service.name", request.ResourceLogs[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "github.com/sirupsen/logrus", request.ResourceLogs[0].ScopeLogs[0].Scope.Name)
+	assert.Len(t, request.ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+}
+
+type collector struct {
+	mu       sync.Mutex
+	requests []ExportLogsServiceRequest
+	failures int
+	// retryAfter is sent in the Retry-After header of failed requests.
+	retryAfter string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures > 0 {
+		c.failures--
+		if c.retryAfter != "" {
+			w.Header().Set("Retry-After", c.retryAfter)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	var request ExportLogsServiceRequest
+	if err := json.Unmarshal(body, &request); err != nil || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.requests = append(c.requests, request)
+}
+
+func (c *collector) records() []LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var records []LogRecord
+	for _, request := range c.requests {
+		records = append(records, request.ResourceLogs[0].ScopeLogs[0].LogRecords...)
+	}
+	return records
+}
+
+func TestHookBatchesAndFlushes(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	hook := NewHook(Config{
+		Endpoint:      server.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Info("one")
+	logger.Info("two")
+	logger.Warn("three")
+	assert.NoError(t, hook.Flush())
+
+	records := c.records()
+	assert.Len(t, records, 3)
+	assert.Equal(t, "three", *records[2].Body.StringValue)
+	assert.Len(t, c.requests, 2)
+}
+
+func TestHookRetries(t *testing.T) {
+	c := &collector{failures: 2}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	hook := NewHook(Config{Endpoint: server.URL, RetryBackoff: time.Millisecond})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("retried")
+	assert.NoError(t, hook.Flush())
+	assert.Len(t, c.records(), 1)
+}
+
+func TestHookRetryAfterCapped(t *testing.T) {
+	c := &collector{failures: 1, retryAfter: "3600"}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	hook := NewHook(Config{Endpoint: server.URL, RetryBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("retried")
+	start := time.Now()
+	assert.NoError(t, hook.Flush())
+	assert.True(t, time.Since(start) < time.Second, "waited for the Retry-After of the collector")
+	assert.Len(t, c.records(), 1)
+}
+
+func TestHookRetryAfterDate(t *testing.T) {
+	c := &collector{failures: 1, retryAfter: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	hook := NewHook(Config{Endpoint: server.URL, RetryBackoff: time.Millisecond, MaxBackoff: 100 * time.Millisecond})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("retried")
+	start := time.Now()
+	assert.NoError(t, hook.Flush())
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "ignored the Retry-After date of the collector")
+	assert.Len(t, c.records(), 1)
+}
+
+func TestHookBackoffCapped(t *testing.T) {
+	c := &collector{failures: 6}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	// Doubling without a cap, the retries would wait over a second.
+	hook := NewHook(Config{Endpoint: server.URL, MaxRetries: 6, RetryBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("retried")
+	start := time.Now()
+	assert.NoError(t, hook.Flush())
+	assert.True(t, time.Since(start) < time.Second, "backoff grew beyond MaxBackoff")
+	assert.Len(t, c.records(), 1)
+}
+
+func TestHookGivesUp(t *testing.T) {
+	c := &collector{failures: 10}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	hook := NewHook(Config{Endpoint: server.URL, MaxRetries: 1, RetryBackoff: time.Millisecond})
+	defer hook.Close()
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("dropped")
+	assert.Error(t, hook.Flush())
+	assert.Equal(t, 8, c.failures)
+}
+
+func TestHookClosed(t *testing.T) {
+	hook := NewHook(Config{Endpoint: "http://127.0.0.1:0"})
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, ErrClosed, hook.Fire(logrus.WithField("k", "v")))
+	assert.Equal(t, ErrClosed, hook.Flush())
+}
+
+func TestHookExitHandler(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+	logrus.RunExitHandlers(context.Background())
+
+	hook := NewHook(Config{Endpoint: server.URL, FlushInterval: time.Hour})
+	defer hook.Close()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	logger.Info("flushed on exit")
+
+	reports := logrus.RunExitHandlers(context.Background())
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, "otlp hook", reports[0].Name)
+		assert.NoError(t, reports[0].Err)
+	}
+	assert.Len(t, c.records(), 1)
+}
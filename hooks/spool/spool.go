@@ -0,0 +1,551 @@
+package logrus_spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/formatters/msgpack"
+)
+
+// ErrClosed is returned by Fire and Flush once the hook has been closed.
+var ErrClosed = errors.New("spool: hook is closed")
+
+// Config configures a Hook.
+type Config struct {
+	// Dir is the directory holding the segment files. It's created if
+	// needed, and must not be shared by several hooks.
+	Dir string
+	// Target is the hook the spooled entries are delivered to, at the levels
+	// it returns from Levels when the spool is opened.
+	Target logrus.Hook
+
+	// SegmentSize is the size in bytes beyond which a new segment file is
+	// started. Defaults to 4MiB.
+	SegmentSize int64
+	// MaxSize is the size in bytes of all the segments beyond which the
+	// oldest segments are deleted, losing their entries. Defaults to 256MiB.
+	MaxSize int64
+	// Sync flushes every entry to stable storage before Fire returns, which
+	// survives power losses but is much slower.
+	Sync bool
+
+	// BatchSize is the maximum number of entries delivered between two
+	// acknowledgments. Defaults to 100.
+	BatchSize int
+	// RetryInterval is the delay before delivering again after the target
+	// failed, doubled on each following failure up to MaxRetryInterval.
+	// Default to one second and one minute.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// Logger is set as the Logger of the entries delivered to Target.
+	// Defaults to the standard logger.
+	Logger *logrus.Logger
+
+	// Levels the hook fires on. Defaults to all levels.
+	Levels []logrus.Level
+}
+
+// Stats describe the content of a spool.
+type Stats struct {
+	// Bytes of entries waiting for delivery, and the number of segment files
+	// holding them.
+	PendingBytes int64
+	Segments     int
+	// Segments deleted because the spool exceeded MaxSize.
+	DroppedSegments uint64
+	// LastError is the last delivery error, cleared by the next successful
+	// delivery.
+	LastError error
+}
+
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// Hook is a write-ahead spool in front of another hook. Fire appends entries
+// to segment files in a local directory, and a background goroutine delivers
+// them to the target hook, deleting them once acknowledged: when Fire, and
+// Flush for targets implementing logrus.Flusher, succeed. Undelivered entries
+// survive restarts, so delivery is at least once: after a failure or a crash
+// some entries may be delivered twice. Segments corrupted by a crash are
+// truncated at the first invalid entry when the spool is opened. Until Close,
+// pending entries are flushed on exit, see logrus.RegisterExitFlusher.
+type Hook struct {
+	config Config
+	target logrus.LevelHooks
+
+	mu       sync.Mutex
+	segments []segment
+	active   *os.File
+	ackSeq   uint64
+	ackOff   int64
+	dropped  uint64
+	lastErr  error
+	closed   bool
+	wake     chan struct{}
+	flushes  chan chan error
+	done     chan struct{}
+	stopped  chan struct{}
+
+	exitHandle logrus.ExitHandle
+}
+
+// NewHook opens the spool in config.Dir, recovering the entries left by a
+// previous process, and starts delivering them to config.Target.
+func NewHook(config Config) (*Hook, error) {
+	if config.Target == nil {
+		return nil, errors.New("spool: no target hook")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = 4 << 20
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = 256 << 20
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	if config.MaxRetryInterval <= 0 {
+		config.MaxRetryInterval = time.Minute
+	}
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+	if config.Levels == nil {
+		config.Levels = logrus.AllLevels
+	}
+
+	hook := &Hook{
+		config:  config,
+		target:  logrus.LevelHooks{},
+		wake:    make(chan struct{}, 1),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	hook.target.Add(config.Target)
+	if err := hook.open(); err != nil {
+		return nil, err
+	}
+	go hook.run()
+	hook.exitHandle = logrus.RegisterExitFlusher("spool hook", hook)
+
+	return hook, nil
+}
+
+func (hook *Hook) segmentPath(seq uint64) string {
+	return filepath.Join(hook.config.Dir, fmt.Sprintf("%016d.seg", seq))
+}
+
+func (hook *Hook) checkpointPath() string {
+	return filepath.Join(hook.config.Dir, "checkpoint")
+}
+
+// open loads the segments and the checkpoint, and truncates corrupted
+// segments.
+func (hook *Hook) open() error {
+	if err := os.MkdirAll(hook.config.Dir, 0700); err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(hook.config.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".seg") {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ".seg"), 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := validSize(hook.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		if size < file.Size() {
+			fmt.Fprintf(os.Stderr, "spool: truncating corrupted segment %s at offset %d\n", name, size)
+			if err := os.Truncate(hook.segmentPath(seq), size); err != nil {
+				return err
+			}
+		}
+		hook.segments = append(hook.segments, segment{seq: seq, size: size})
+	}
+	sort.Sort(bySeq(hook.segments))
+
+	if b, err := ioutil.ReadFile(hook.checkpointPath()); err == nil {
+		// An invalid checkpoint means delivering everything again.
+		fmt.Sscanf(string(b), "%d %d", &hook.ackSeq, &hook.ackOff)
+	}
+
+	// Segments before the checkpoint were delivered, but not deleted yet.
+	for len(hook.segments) > 1 && hook.segments[0].seq < hook.ackSeq {
+		os.Remove(hook.segmentPath(hook.segments[0].seq))
+		hook.segments = hook.segments[1:]
+	}
+
+	seq := uint64(1)
+	if n := len(hook.segments); n > 0 {
+		seq = hook.segments[n-1].seq
+	} else {
+		hook.segments = []segment{{seq: seq}}
+	}
+	hook.active, err = os.OpenFile(hook.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+type bySeq []segment
+
+func (s bySeq) Len() int           { return len(s) }
+func (s bySeq) Less(i, j int) bool { return s[i].seq < s[j].seq }
+func (s bySeq) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// validSize returns the size of the valid records at the start of a
+// segment file.
+func validSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var size int64
+	for {
+		payload, err := readRecord(r)
+		if err != nil {
+			return size, nil
+		}
+		size += recordHeaderSize + int64(len(payload))
+	}
+}
+
+// Records are stored as their length and CRC-32 checksum, both 4 byte big
+// endian integers, followed by their MessagePack encoding.
+const recordHeaderSize = 8
+
+var errCorrupted = errors.New("spool: corrupted record")
+
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length > 64<<20 {
+		return nil, errCorrupted
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errCorrupted
+	}
+	return payload, nil
+}
+
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.config.Levels
+}
+
+// Fire appends the entry to the spool. It fails when the spool can't be
+// written to.
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	payload, err := logrus_msgpack.Marshal(logrus_msgpack.NewRecordMap(entry))
+	if err != nil {
+		return fmt.Errorf("spool: failed to marshal entry, %v", err)
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.closed {
+		return ErrClosed
+	}
+
+	last := &hook.segments[len(hook.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > hook.config.SegmentSize {
+		if err := hook.rotate(); err != nil {
+			return err
+		}
+		last = &hook.segments[len(hook.segments)-1]
+	}
+	n, err := hook.active.Write(record)
+	last.size += int64(n)
+	if err == nil && hook.config.Sync {
+		err = hook.active.Sync()
+	}
+	if err != nil {
+		return err
+	}
+	hook.enforceMaxSize()
+
+	select {
+	case hook.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotate starts a new segment.
+func (hook *Hook) rotate() error {
+	seq := hook.segments[len(hook.segments)-1].seq + 1
+	f, err := os.OpenFile(hook.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	hook.active.Close()
+	hook.active = f
+	hook.segments = append(hook.segments, segment{seq: seq})
+	return nil
+}
+
+// enforceMaxSize deletes the oldest segments, but the active one, while the
+// spool is too large.
+func (hook *Hook) enforceMaxSize() {
+	var total int64
+	for _, s := range hook.segments {
+		total += s.size
+	}
+	for total > hook.config.MaxSize && len(hook.segments) > 1 {
+		total -= hook.segments[0].size
+		os.Remove(hook.segmentPath(hook.segments[0].seq))
+		hook.segments = hook.segments[1:]
+		hook.dropped++
+	}
+}
+
+// Stats returns a snapshot of the state of the spool.
+func (hook *Hook) Stats() Stats {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	stats := Stats{DroppedSegments: hook.dropped, LastError: hook.lastErr}
+	for _, s := range hook.segments {
+		if s.seq < hook.ackSeq {
+			continue
+		}
+		stats.PendingBytes += s.size
+		if s.seq == hook.ackSeq {
+			stats.PendingBytes -= hook.ackOff
+		}
+		if s.size > 0 {
+			stats.Segments++
+		}
+	}
+	return stats
+}
+
+// Flush delivers all the spooled entries, and returns the error of the
+// target if it fails to acknowledge them.
+func (hook *Hook) Flush() error {
+	hook.mu.Lock()
+	closed := hook.closed
+	hook.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	reply := make(chan error)
+	select {
+	case hook.flushes <- reply:
+		return <-reply
+	case <-hook.stopped:
+		return ErrClosed
+	}
+}
+
+// Close stops the delivery and closes the spool, leaving the undelivered
+// entries for the next hook opening it. It doesn't close the target.
+func (hook *Hook) Close() error {
+	hook.exitHandle.Deregister()
+	hook.mu.Lock()
+	if hook.closed {
+		hook.mu.Unlock()
+		return nil
+	}
+	hook.closed = true
+	hook.mu.Unlock()
+
+	close(hook.done)
+	<-hook.stopped
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	return hook.active.Close()
+}
+
+func (hook *Hook) run() {
+	defer close(hook.stopped)
+
+	retryInterval := hook.config.RetryInterval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	deliver := func() error {
+		err := hook.deliver()
+		hook.mu.Lock()
+		hook.lastErr = err
+		hook.mu.Unlock()
+
+		if err == nil {
+			retryInterval = hook.config.RetryInterval
+			return nil
+		}
+		timer.Reset(retryInterval)
+		if retryInterval *= 2; retryInterval > hook.config.MaxRetryInterval {
+			retryInterval = hook.config.MaxRetryInterval
+		}
+		return err
+	}
+
+	failing := false
+	for {
+		select {
+		case <-hook.wake:
+			if !failing {
+				failing = deliver() != nil
+			}
+		case <-timer.C:
+			failing = deliver() != nil
+		case reply := <-hook.flushes:
+			err := deliver()
+			failing = err != nil
+			reply <- err
+		case <-hook.done:
+			return
+		}
+	}
+}
+
+// deliver sends the spooled entries to the target, one batch at a time,
+// moving the checkpoint forward after each acknowledged batch.
+func (hook *Hook) deliver() error {
+	for {
+		hook.mu.Lock()
+		var current segment
+		found, active := false, false
+		for i, s := range hook.segments {
+			if s.seq >= hook.ackSeq {
+				current, found, active = s, true, i == len(hook.segments)-1
+				break
+			}
+		}
+		offset := hook.ackOff
+		if !found {
+			hook.mu.Unlock()
+			return nil
+		}
+		if current.seq != hook.ackSeq {
+			// The segment was deleted, start at the next one.
+			offset = 0
+		}
+		hook.mu.Unlock()
+
+		if offset >= current.size {
+			if active {
+				return nil
+			}
+			hook.acknowledge(current.seq+1, 0, current.seq)
+			continue
+		}
+
+		entries, end, err := hook.readBatch(current, offset)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := hook.target.Fire(entry.Level, entry); err != nil {
+				return err
+			}
+		}
+		if flusher, ok := hook.config.Target.(logrus.Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+		hook.acknowledge(current.seq, end, 0)
+	}
+}
+
+// readBatch reads up to BatchSize entries of a segment from offset, and
+// returns the offset following them. A corrupted record ends the segment.
+func (hook *Hook) readBatch(s segment, offset int64) ([]*logrus.Entry, int64, error) {
+	f, err := os.Open(hook.segmentPath(s.seq))
+	if os.IsNotExist(err) {
+		// Deleted to enforce MaxSize.
+		return nil, s.size, nil
+	} else if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, 0); err != nil {
+		return nil, offset, err
+	}
+
+	r := bufio.NewReader(io.LimitReader(f, s.size-offset))
+	var entries []*logrus.Entry
+	for len(entries) < hook.config.BatchSize && offset < s.size {
+		payload, err := readRecord(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "spool: skipping the end of segment %d, %v\n", s.seq, err)
+			return entries, s.size, nil
+		}
+		offset += recordHeaderSize + int64(len(payload))
+
+		record, err := logrus_msgpack.NewDecoder(bytes.NewReader(payload)).DecodeRecord()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "spool: skipping invalid entry, %v\n", err)
+			continue
+		}
+		entries = append(entries, &logrus.Entry{
+			Logger:  hook.config.Logger,
+			Data:    record.Data,
+			Time:    record.Time,
+			Level:   record.Level,
+			Message: record.Message,
+		})
+	}
+	return entries, offset, nil
+}
+
+// acknowledge moves the checkpoint, and deletes the segment done, if any.
+func (hook *Hook) acknowledge(seq uint64, offset int64, done uint64) {
+	hook.mu.Lock()
+	hook.ackSeq, hook.ackOff = seq, offset
+	if done != 0 && len(hook.segments) > 1 && hook.segments[0].seq == done {
+		os.Remove(hook.segmentPath(done))
+		hook.segments = hook.segments[1:]
+	}
+	hook.mu.Unlock()
+
+	// Written to a temporary file first, so a crash can't leave a
+	// partial checkpoint behind.
+	tmp := hook.checkpointPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", seq, offset)), 0600); err == nil {
+		os.Rename(tmp, hook.checkpointPath())
+	}
+}
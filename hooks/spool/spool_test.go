@@ -0,0 +1,296 @@
+package logrus_spool
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// target records the entries it's fired with, at all levels unless levels
+// is set. It fails to fire while down, and to flush while flushErr is set.
+type target struct {
+	mu       sync.Mutex
+	levels   []logrus.Level
+	down     bool
+	flushErr error
+	fired    []*logrus.Entry
+	flushes  int
+}
+
+func (t *target) Levels() []logrus.Level {
+	if t.levels != nil {
+		return t.levels
+	}
+	return logrus.AllLevels
+}
+
+func (t *target) Fire(entry *logrus.Entry) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.down {
+		return errors.New("no connectivity")
+	}
+	t.fired = append(t.fired, entry)
+	return nil
+}
+
+func (t *target) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flushes++
+	return t.flushErr
+}
+
+func (t *target) set(down bool, flushErr error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down, t.flushErr = down, flushErr
+}
+
+func (t *target) messages() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var messages []string
+	for _, entry := range t.fired {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "logrus_spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func newLogger(hook *Hook) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(hook)
+	return logger
+}
+
+func segments(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func TestDelivery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tg := &target{}
+	hook, err := NewHook(Config{Dir: dir, Target: tg, SegmentSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.WithFields(logrus.Fields{"animal": "walrus", "size": 10}).Warn("A walrus appears")
+	for i := 0; i < 5; i++ {
+		logger.Info("Another walrus")
+	}
+	assert.NoError(t, hook.Flush())
+
+	messages := tg.messages()
+	assert.Len(t, messages, 6)
+	assert.Equal(t, "A walrus appears", messages[0])
+	entry := tg.fired[0]
+	assert.Equal(t, logrus.WarnLevel, entry.Level)
+	assert.Equal(t, logrus.Fields{"animal": "walrus", "size": int64(10)}, entry.Data)
+	assert.WithinDuration(t, time.Now(), entry.Time, time.Minute)
+
+	// Delivered segments are deleted, but the active one.
+	assert.Len(t, segments(t, dir), 1)
+	assert.Equal(t, int64(0), hook.Stats().PendingBytes)
+}
+
+func TestTargetLevels(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tg := &target{levels: []logrus.Level{logrus.ErrorLevel}}
+	hook, err := NewHook(Config{Dir: dir, Target: tg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("A walrus appears")
+	logger.Error("The walrus bites")
+	assert.NoError(t, hook.Flush())
+
+	assert.Equal(t, []string{"The walrus bites"}, tg.messages())
+	assert.Equal(t, int64(0), hook.Stats().PendingBytes)
+}
+
+func TestSurvivesRestart(t *testing.T) {
+	logrus.RunExitHandlers(context.Background())
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	offline := &target{down: true}
+	hook, err := NewHook(Config{Dir: dir, Target: offline, SegmentSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newLogger(hook)
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("third")
+	assert.Error(t, hook.Flush())
+
+	stats := hook.Stats()
+	assert.True(t, stats.PendingBytes > 0)
+	assert.Error(t, stats.LastError)
+	assert.NoError(t, hook.Close())
+	assert.Equal(t, ErrClosed, hook.Fire(logrus.NewEntry(logger)))
+	// Closed hooks don't stay registered for flushing on exit
+	assert.Empty(t, logrus.RunExitHandlers(context.Background()))
+
+	online := &target{}
+	hook, err = NewHook(Config{Dir: dir, Target: online})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, []string{"first", "second", "third"}, online.messages())
+	assert.NoError(t, hook.Stats().LastError)
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tg := &target{flushErr: errors.New("not acknowledged")}
+	hook, err := NewHook(Config{Dir: dir, Target: tg, RetryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	logger.Info("first")
+	assert.Error(t, hook.Flush())
+	tg.set(false, nil)
+	assert.NoError(t, hook.Flush())
+
+	// Fired again as the first delivery wasn't acknowledged.
+	messages := tg.messages()
+	assert.Equal(t, "first", messages[len(messages)-1])
+	assert.True(t, len(messages) >= 2)
+
+	// Acknowledged entries are not delivered again.
+	logger.Info("second")
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, []string{"second"}, tg.messages()[len(messages):])
+}
+
+func TestCorruptionRecovery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	hook, err := NewHook(Config{Dir: dir, Target: &target{down: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newLogger(hook)
+	logger.Info("first")
+	logger.Info("second")
+	hook.Close()
+
+	// A torn write: the header of a record without its payload.
+	path := segments(t, dir)[0]
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 50, 1, 2, 3, 4, 5})
+	f.Close()
+
+	tg := &target{}
+	hook, err = NewHook(Config{Dir: dir, Target: tg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	logger = newLogger(hook)
+	logger.Info("third")
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, []string{"first", "second", "third"}, tg.messages())
+}
+
+func TestCorruptedRecordChecksum(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	hook, err := NewHook(Config{Dir: dir, Target: &target{down: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := newLogger(hook)
+	logger.Info("first")
+	logger.Info("second")
+	hook.Close()
+
+	path := segments(t, dir)[0]
+	b, _ := ioutil.ReadFile(path)
+	b[len(b)-1] ^= 0xff
+	ioutil.WriteFile(path, b, 0600)
+
+	tg := &target{}
+	hook, err = NewHook(Config{Dir: dir, Target: tg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	assert.NoError(t, hook.Flush())
+	assert.Equal(t, []string{"first"}, tg.messages())
+}
+
+func TestMaxSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tg := &target{down: true}
+	hook, err := NewHook(Config{Dir: dir, Target: tg, SegmentSize: 100, MaxSize: 300, RetryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+	logger := newLogger(hook)
+
+	for i := 0; i < 20; i++ {
+		logger.Info("walrus")
+	}
+	stats := hook.Stats()
+	assert.True(t, stats.DroppedSegments > 0)
+	assert.True(t, stats.PendingBytes <= 300, "%d", stats.PendingBytes)
+	assert.Equal(t, len(segments(t, dir)), stats.Segments)
+
+	tg.set(false, nil)
+	assert.NoError(t, hook.Flush())
+	messages := tg.messages()
+	assert.True(t, len(messages) > 0 && len(messages) < 20, "%d", len(messages))
+}
+
+func TestNoTarget(t *testing.T) {
+	_, err := NewHook(Config{Dir: "unused"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FakeClock is a logrus.Clock controlled by the test: its time only changes
+// when set or advanced, or by a fixed step each time an entry is logged.
+//
+//   logger, hook := test.NewNullLogger()
+//   clock := test.NewFakeClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+//   logger.SetClock(clock)
+//   logger.Info("first")
+//   clock.Advance(time.Minute)
+//   logger.Info("a minute later")
+type FakeClock struct {
+	mu    sync.Mutex
+	start time.Time
+	now   time.Time
+	step  time.Duration
+}
+
+var _ logrus.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock started, and stopped, at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{start: start, now: start}
+}
+
+// Now returns the time of the clock, then advances it by the step.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Start returns the time the clock was started at.
+func (c *FakeClock) Start() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.start
+}
+
+// Set sets the time of the clock.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the time of the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetStep makes the clock advance by step after each call to Now, so that
+// entries logged in a row get distinct times. A zero step stops it.
+func (c *FakeClock) SetStep(step time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step = step
+}
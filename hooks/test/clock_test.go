@@ -0,0 +1,52 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	logger, hook := NewNullLogger()
+	clock := NewFakeClock(start)
+	logger.SetClock(clock)
+
+	logger.Info("first")
+	logger.Info("second")
+	clock.Advance(time.Minute)
+	logger.Info("third")
+	clock.Set(start.Add(time.Hour))
+	clock.SetStep(time.Second)
+	logger.Info("fourth")
+	logger.Info("fifth")
+
+	var times []time.Time
+	for _, entry := range hook.AllEntries() {
+		times = append(times, entry.Time)
+	}
+	assert.Equal(t, []time.Time{
+		start,
+		start,
+		start.Add(time.Minute),
+		start.Add(time.Hour),
+		start.Add(time.Hour + time.Second),
+	}, times)
+	assert.Equal(t, start, clock.Start())
+}
+
+func TestFakeClockElapsed(t *testing.T) {
+	var buffer bytes.Buffer
+	clock := NewFakeClock(time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC))
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &logrus.TextFormatter{ForceColors: true}
+	logger.Clock = clock
+
+	clock.Advance(42 * time.Second)
+	logger.Info("later")
+	assert.Contains(t, buffer.String(), "INFO\x1b[0m[0042] later")
+}
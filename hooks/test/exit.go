@@ -0,0 +1,106 @@
+package test
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Exit is a call to the ExitFunc of a logger, recorded by an ExitRecorder.
+type Exit struct {
+	Code int
+	// Entry is the fatal entry logged before exiting, or nil when the level
+	// of the logger filtered it out.
+	Entry *logrus.Entry
+}
+
+// ExitRecorder records the exits of a logger instead of terminating the
+// program, so that Fatal paths can be tested. The Fatal methods return
+// once the exit is recorded, and the exit handlers aren't run:
+//
+//   logger, _ := test.NewNullLogger()
+//   exits := test.NewExitRecorder(logger)
+//   logger.WithField("db", "down").Fatal("Cannot start")
+//   assert.Equal(t, 1, exits.Code())
+//   assert.Equal(t, "Cannot start", exits.Entry().Message)
+type ExitRecorder struct {
+	mu      sync.Mutex
+	exits   []Exit
+	pending *logrus.Entry
+}
+
+// NewExitRecorder sets the ExitFunc of logger to record its exits, and
+// installs a hook recording the fatal entries.
+func NewExitRecorder(logger *logrus.Logger) *ExitRecorder {
+	r := new(ExitRecorder)
+	logger.Hooks.Add(fatalHook{r})
+	logger.SetExitFunc(r.exit)
+	return r
+}
+
+type fatalHook struct {
+	recorder *ExitRecorder
+}
+
+func (h fatalHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.FatalLevel}
+}
+
+func (h fatalHook) Fire(e *logrus.Entry) error {
+	// Keep a copy, as the logger keeps using e after firing the hooks
+	entry := *e
+	h.recorder.mu.Lock()
+	h.recorder.pending = &entry
+	h.recorder.mu.Unlock()
+	return nil
+}
+
+func (r *ExitRecorder) exit(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exits = append(r.exits, Exit{Code: code, Entry: r.pending})
+	r.pending = nil
+}
+
+// Exited reports whether the logger exited.
+func (r *ExitRecorder) Exited() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.exits) > 0
+}
+
+// Code returns the code of the last exit, or -1 if the logger didn't exit.
+func (r *ExitRecorder) Code() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.exits) == 0 {
+		return -1
+	}
+	return r.exits[len(r.exits)-1].Code
+}
+
+// Entry returns the fatal entry of the last exit, or nil if the logger
+// didn't exit or the entry was filtered out.
+func (r *ExitRecorder) Entry() *logrus.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.exits) == 0 {
+		return nil
+	}
+	return r.exits[len(r.exits)-1].Entry
+}
+
+// Exits returns all the exits recorded.
+func (r *ExitRecorder) Exits() []Exit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exit(nil), r.exits...)
+}
+
+// Reset forgets the exits recorded.
+func (r *ExitRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exits = nil
+	r.pending = nil
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitRecorder(t *testing.T) {
+	logger, hook := NewNullLogger()
+	exits := NewExitRecorder(logger)
+	assert.False(t, exits.Exited())
+	assert.Equal(t, -1, exits.Code())
+	assert.Nil(t, exits.Entry())
+
+	logger.WithField("db", "down").Fatal("Cannot start")
+	assert.True(t, exits.Exited())
+	assert.Equal(t, 1, exits.Code())
+	if assert.NotNil(t, exits.Entry()) {
+		assert.Equal(t, "Cannot start", exits.Entry().Message)
+		assert.Equal(t, "down", exits.Entry().Data["db"])
+	}
+	assert.Equal(t, logrus.FatalLevel, hook.LastEntry().Level)
+
+	logger.Fatalf("Cannot %s", "stop")
+	logger.WithField("a", 1).Fatalln("Cannot", "restart")
+	exitsList := exits.Exits()
+	if assert.Len(t, exitsList, 3) {
+		assert.Equal(t, "Cannot stop", exitsList[1].Entry.Message)
+		assert.Equal(t, "Cannot restart", exitsList[2].Entry.Message)
+	}
+
+	exits.Reset()
+	assert.False(t, exits.Exited())
+}
+
+func TestExitRecorderFiltered(t *testing.T) {
+	logger, hook := NewNullLogger()
+	exits := NewExitRecorder(logger)
+	logger.Level = logrus.PanicLevel
+
+	logger.Fatal("Filtered out")
+	assert.Nil(t, hook.LastEntry())
+	assert.True(t, exits.Exited())
+	assert.Equal(t, 1, exits.Code())
+	assert.Nil(t, exits.Entry())
+}
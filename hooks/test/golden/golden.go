@@ -0,0 +1,229 @@
+// Package golden compares the output of logrus formatters against golden
+// files, to catch regressions in spacing or quoting that assertions on
+// substrings let through:
+//
+//   func TestFormatter(t *testing.T) {
+//     r := golden.NewRecorder(&MyFormatter{})
+//     r.Logger.WithField("animal", "walrus").Info("A walrus appears")
+//     r.Logger.WithError(errors.New("tusk broken")).Error("Walrus hurt")
+//     r.Assert(t, "my_formatter")
+//   }
+//
+// The output is compared with testdata/my_formatter.golden. Run the tests
+// with the -update flag to write the golden files from the current output:
+//
+//   go test -run TestFormatter -update
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// Time is the time the clock of a new Recorder is stopped at.
+var Time = time.Date(2017, time.February, 3, 4, 5, 6, 789000000, time.UTC)
+
+var update = updateFlag()
+
+// updateFlag defines the -update flag, unless the test binary already did.
+func updateFlag() *bool {
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if _, ok := getter.Get().(bool); ok {
+				return nil
+			}
+		}
+	}
+	return flag.Bool("update", false, "update the golden files")
+}
+
+// updating reports whether golden files should be written rather than
+// compared against.
+func updating() bool {
+	if update != nil {
+		return *update
+	}
+	return flag.Lookup("update").Value.(flag.Getter).Get().(bool)
+}
+
+// A Normalizer replaces the volatile parts of formatted output.
+type Normalizer func(output []byte) []byte
+
+var (
+	timestampRegexp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	callerRegexp    = regexp.MustCompile(`(?:[A-Za-z]:)?[^\s"'=:]*[/\\]([^/\\\s"'=:]+\.go):\d+`)
+)
+
+// Timestamps replaces timestamps in RFC 3339 format, with or without
+// fractional seconds and time zone, by <time>. Those of entries logged
+// through a Recorder are frozen already, so it is mostly useful for
+// timestamps logged in fields.
+func Timestamps(output []byte) []byte {
+	return timestampRegexp.ReplaceAll(output, []byte("<time>"))
+}
+
+// CallerPaths replaces file paths followed by a line number, such as those
+// of Entry.Caller, by the base name of the file and <line>.
+func CallerPaths(output []byte) []byte {
+	return callerRegexp.ReplaceAll(output, []byte("$1:<line>"))
+}
+
+// PIDs replaces the process ID of the test binary by <pid>. It isn't a
+// default normalizer, since any number in the output may equal the PID.
+func PIDs(output []byte) []byte {
+	pid := regexp.MustCompile(`\b` + strconv.Itoa(os.Getpid()) + `\b`)
+	return pid.ReplaceAll(output, []byte("<pid>"))
+}
+
+// DefaultNormalizers are the normalizers of a new Recorder. Timestamps isn't
+// one of them, since timestamp formats are part of what golden files check.
+var DefaultNormalizers = []Normalizer{CallerPaths}
+
+// Recorder records the output of Logger, for comparing it with golden files.
+type Recorder struct {
+	// Logger formats entries with the formatter given to NewRecorder, at
+	// all levels, with Clock.
+	Logger *logrus.Logger
+
+	// Clock is the clock of Logger, stopped at Time until advanced.
+	Clock *test.FakeClock
+
+	// Normalizers are applied in order to the output before comparing it.
+	Normalizers []Normalizer
+
+	buffer bytes.Buffer
+}
+
+// NewRecorder returns a Recorder logging with formatter.
+func NewRecorder(formatter logrus.Formatter) *Recorder {
+	r := &Recorder{
+		Normalizers: DefaultNormalizers,
+	}
+	r.Logger = logrus.New()
+	r.Logger.Out = &r.buffer
+	r.Logger.Formatter = formatter
+	r.Logger.Level = logrus.DebugLevel
+	r.Clock = test.NewFakeClock(Time)
+	r.Logger.Clock = r.Clock
+	return r
+}
+
+// Output returns the normalized output logged so far.
+func (r *Recorder) Output() []byte {
+	output := append([]byte(nil), r.buffer.Bytes()...)
+	for _, normalize := range r.Normalizers {
+		output = normalize(output)
+	}
+	return output
+}
+
+// Reset discards the output logged so far.
+func (r *Recorder) Reset() {
+	r.buffer.Reset()
+}
+
+// Assert compares the normalized output logged so far with the golden file
+// testdata/<name>.golden, or writes it there with the -update flag.
+func (r *Recorder) Assert(tb testing.TB, name string) {
+	tb.Helper()
+	Assert(tb, name, r.Output())
+}
+
+// Assert compares got with the golden file testdata/<name>.golden, or
+// writes it there with the -update flag. Differences are reported line by
+// line, quoted so that spacing is visible.
+func Assert(tb testing.TB, name string, got []byte) {
+	tb.Helper()
+	path := filepath.Join("testdata", name+".golden")
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			tb.Fatalf("golden: failed to create directory, %v", err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			tb.Fatalf("golden: failed to update %s, %v", path, err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		tb.Errorf("golden: %s doesn't exist, run the test with -update to create it", path)
+		return
+	} else if err != nil {
+		tb.Fatalf("golden: failed to read %s, %v", path, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		tb.Errorf("golden: output differs from %s (-want +got):\n%s", path, Diff(want, got))
+	}
+}
+
+// Diff returns the differences between the lines of want and got, lines
+// only in want prefixed with -, lines only in got with + and common lines
+// with a space. Lines are quoted, so that differences in spacing show.
+func Diff(want, got []byte) string {
+	a, b := splitLines(want), splitLines(got)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+strconv.Quote(a[i]))
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, "+ "+strconv.Quote(b[j]))
+			j++
+		default:
+			lines = append(lines, "- "+strconv.Quote(a[i]))
+			i++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitLines splits output after each newline, keeping them so that a
+// missing final newline shows.
+func splitLines(output []byte) []string {
+	var lines []string
+	for len(output) > 0 {
+		i := bytes.IndexByte(output, '\n') + 1
+		if i == 0 {
+			i = len(output)
+		}
+		lines = append(lines, string(output[:i]))
+		output = output[i:]
+	}
+	return lines
+}
@@ -0,0 +1,123 @@
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the failures of assertions, instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func logSamples(logger *logrus.Logger) {
+	logger.WithFields(logrus.Fields{"animal": "walrus", "size": 10}).Info("A walrus appears")
+	logger.WithFields(logrus.Fields{"omg": true, "number": 122, "empty": ""}).Warn("The group's number increased tremendously!")
+	logger.WithError(errors.New("tusk broken")).Error("Walrus hurt")
+	logger.Debug("")
+}
+
+func TestTextFormatter(t *testing.T) {
+	r := NewRecorder(&logrus.TextFormatter{QuoteEmptyFields: true})
+	logSamples(r.Logger)
+	r.Assert(t, "text")
+}
+
+func TestJSONFormatter(t *testing.T) {
+	r := NewRecorder(&logrus.JSONFormatter{})
+	logSamples(r.Logger)
+	r.Assert(t, "json")
+}
+
+func TestNormalizers(t *testing.T) {
+	assert.Equal(t, "at=<time> and <time> not 2017",
+		string(Timestamps([]byte("at=2017-02-03T04:05:06.789Z and 2017-02-03 04:05:06+01:00 not 2017"))))
+	assert.Equal(t, `file=golden_test.go:<line> "file=main.go:<line>" x.go:y`,
+		string(CallerPaths([]byte(`file=/home/walrus/src/golden_test.go:42 "file=C:\src\main.go:7" x.go:y`))))
+	assert.Equal(t, fmt.Sprintf("pid=<pid> pid=%d1", os.Getpid()),
+		string(PIDs([]byte(fmt.Sprintf("pid=%d pid=%d1", os.Getpid(), os.Getpid())))))
+}
+
+func TestRecorderCaller(t *testing.T) {
+	r := NewRecorder(&logrus.JSONFormatter{})
+	r.Logger.WithField("file", "/usr/src/app/main.go:12").Info("Started")
+	assert.Contains(t, string(r.Output()), `"file":"main.go:<line>"`)
+	assert.Contains(t, string(r.Output()), `"time":"2017-02-03T04:05:06Z"`)
+
+	r.Reset()
+	assert.Empty(t, r.Output())
+}
+
+func TestRecorderKeepsPIDs(t *testing.T) {
+	r := NewRecorder(&logrus.JSONFormatter{})
+	r.Logger.WithField("number", os.Getpid()).Info("Counted")
+	assert.Contains(t, string(r.Output()), fmt.Sprintf(`"number":%d`, os.Getpid()))
+}
+
+func TestAssertMismatch(t *testing.T) {
+	r := NewRecorder(&logrus.TextFormatter{})
+	r.Logger.Info("A walrus appears")
+	r.Logger.Info("A walrus  leaves")
+	tb := &recorder{TB: t}
+
+	Assert(tb, "missing", r.Output())
+	Assert(tb, "mismatch", r.Output())
+	if assert.Len(t, tb.errors, 2) {
+		assert.Equal(t, filepath.Join("golden: testdata", "missing.golden")+" doesn't exist, run the test with -update to create it", tb.errors[0])
+		assert.Equal(t, `golden: output differs from `+filepath.Join("testdata", "mismatch.golden")+` (-want +got):
+  "time=\"2017-02-03T04:05:06Z\" level=info msg=\"A walrus appears\" \n"
+- "time=\"2017-02-03T04:05:06Z\" level=info msg=\"A walrus leaves\" \n"
++ "time=\"2017-02-03T04:05:06Z\" level=info msg=\"A walrus  leaves\" \n"`, tb.errors[1])
+	}
+}
+
+func TestAssertUpdate(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	dir, err := ioutil.TempDir("", "golden")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	*update = true
+	Assert(t, "updated", []byte("walrus\n"))
+	*update = false
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "testdata", "updated.golden"))
+	assert.NoError(t, err)
+	assert.Equal(t, "walrus\n", string(b))
+	Assert(t, "updated", []byte("walrus\n"))
+}
+
+func TestDiff(t *testing.T) {
+	assert.Equal(t, `  "a\n"
+- "b\n"
++ "B\n"
+  "c\n"
++ "d"`, Diff([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd")))
+	assert.Equal(t, `- "a\n"
++ "a"`, Diff([]byte("a\n"), []byte("a")))
+	assert.Equal(t, "", Diff(nil, nil))
+}
+
+func TestColoredTextFormatter(t *testing.T) {
+	r := NewRecorder(&logrus.TextFormatter{ForceColors: true})
+	logSamples(r.Logger)
+	r.Clock.Advance(90 * time.Second)
+	r.Logger.Info("Later")
+	r.Assert(t, "text_colored")
+}
@@ -0,0 +1,286 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// A Matcher selects entries, for the Find and Assert methods of Hook.
+type Matcher interface {
+	Match(entry *logrus.Entry) bool
+	// String describes the matched entries in failure messages.
+	String() string
+}
+
+type matcher struct {
+	match       func(entry *logrus.Entry) bool
+	description string
+}
+
+func (m *matcher) Match(entry *logrus.Entry) bool { return m.match(entry) }
+func (m *matcher) String() string                 { return m.description }
+
+// MatcherFunc returns a Matcher using match, described by description.
+func MatcherFunc(description string, match func(entry *logrus.Entry) bool) Matcher {
+	return &matcher{match: match, description: description}
+}
+
+// AtLevel matches entries logged at one of levels.
+func AtLevel(levels ...logrus.Level) Matcher {
+	names := make([]string, len(levels))
+	for i, level := range levels {
+		names[i] = level.String()
+	}
+	return MatcherFunc("level "+strings.Join(names, " or "), func(entry *logrus.Entry) bool {
+		for _, level := range levels {
+			if entry.Level == level {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// AtLeast matches entries at least as severe as level: AtLeast(WarnLevel)
+// matches warnings, errors, fatal and panic entries.
+func AtLeast(level logrus.Level) Matcher {
+	return MatcherFunc("level "+level.String()+" or above", func(entry *logrus.Entry) bool {
+		return entry.Level <= level
+	})
+}
+
+// Message matches entries with exactly this message.
+func Message(message string) Matcher {
+	return MatcherFunc(fmt.Sprintf("message %q", message), func(entry *logrus.Entry) bool {
+		return entry.Message == message
+	})
+}
+
+// MessageContains matches entries whose message contains substr.
+func MessageContains(substr string) Matcher {
+	return MatcherFunc(fmt.Sprintf("message containing %q", substr), func(entry *logrus.Entry) bool {
+		return strings.Contains(entry.Message, substr)
+	})
+}
+
+// MessageMatches matches entries whose message matches the regular
+// expression pattern. It panics if pattern doesn't compile.
+func MessageMatches(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return MatcherFunc(fmt.Sprintf("message matching /%s/", pattern), func(entry *logrus.Entry) bool {
+		return re.MatchString(entry.Message)
+	})
+}
+
+// HasField matches entries with a field named key.
+func HasField(key string) Matcher {
+	return MatcherFunc(fmt.Sprintf("field %q", key), func(entry *logrus.Entry) bool {
+		_, ok := entry.Data[key]
+		return ok
+	})
+}
+
+// Field matches entries whose field key equals value. Values are compared
+// with reflect.DeepEqual, except errors which are compared by message when
+// value is a string.
+func Field(key string, value interface{}) Matcher {
+	return MatcherFunc(fmt.Sprintf("field %s=%#v", key, value), func(entry *logrus.Entry) bool {
+		v, ok := entry.Data[key]
+		if !ok {
+			return false
+		}
+		if err, isError := v.(error); isError {
+			if s, isString := value.(string); isString {
+				return err.Error() == s
+			}
+		}
+		return reflect.DeepEqual(v, value)
+	})
+}
+
+// FieldFunc matches entries with a field named key whose value satisfies
+// predicate.
+func FieldFunc(key string, predicate func(value interface{}) bool) Matcher {
+	return MatcherFunc(fmt.Sprintf("field %q satisfying predicate", key), func(entry *logrus.Entry) bool {
+		v, ok := entry.Data[key]
+		return ok && predicate(v)
+	})
+}
+
+// All matches entries matched by all of matchers, and any entry when there
+// are none.
+func All(matchers ...Matcher) Matcher {
+	if len(matchers) == 1 {
+		return matchers[0]
+	}
+	return MatcherFunc(describe(matchers, " and "), func(entry *logrus.Entry) bool {
+		for _, m := range matchers {
+			if !m.Match(entry) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Any matches entries matched by one of matchers at least.
+func Any(matchers ...Matcher) Matcher {
+	return MatcherFunc("("+describe(matchers, " or ")+")", func(entry *logrus.Entry) bool {
+		for _, m := range matchers {
+			if m.Match(entry) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches entries not matched by m.
+func Not(m Matcher) Matcher {
+	return MatcherFunc("not ("+m.String()+")", func(entry *logrus.Entry) bool {
+		return !m.Match(entry)
+	})
+}
+
+func describe(matchers []Matcher, sep string) string {
+	if len(matchers) == 0 {
+		return "any entry"
+	}
+	descriptions := make([]string, len(matchers))
+	for i, m := range matchers {
+		descriptions[i] = m.String()
+	}
+	return strings.Join(descriptions, sep)
+}
+
+// Find returns the entries matched by all of matchers, in the order they
+// were logged.
+func (t *Hook) Find(matchers ...Matcher) []*logrus.Entry {
+	m := All(matchers...)
+	var found []*logrus.Entry
+	for _, entry := range t.AllEntries() {
+		if m.Match(entry) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// Count returns the number of entries matched by all of matchers.
+func (t *Hook) Count(matchers ...Matcher) int {
+	return len(t.Find(matchers...))
+}
+
+// AssertLogged fails the test unless an entry matched by all of matchers was
+// logged, and returns the first one.
+func (t *Hook) AssertLogged(tb testing.TB, matchers ...Matcher) *logrus.Entry {
+	tb.Helper()
+	found := t.Find(matchers...)
+	if len(found) == 0 {
+		t.fail(tb, fmt.Sprintf("expected an entry with %s, found none", All(matchers...)), All(matchers...))
+		return nil
+	}
+	return found[0]
+}
+
+// AssertNotLogged fails the test if an entry matched by all of matchers was
+// logged.
+func (t *Hook) AssertNotLogged(tb testing.TB, matchers ...Matcher) {
+	tb.Helper()
+	if n := t.Count(matchers...); n > 0 {
+		t.fail(tb, fmt.Sprintf("expected no entry with %s, found %d", All(matchers...), n), All(matchers...))
+	}
+}
+
+// AssertCount fails the test unless exactly n entries matched by all of
+// matchers were logged.
+func (t *Hook) AssertCount(tb testing.TB, n int, matchers ...Matcher) {
+	tb.Helper()
+	if found := t.Count(matchers...); found != n {
+		t.fail(tb, fmt.Sprintf("expected %d entries with %s, found %d", n, All(matchers...), found), All(matchers...))
+	}
+}
+
+// AssertNoneAbove fails the test if an entry more severe than level was
+// logged: AssertNoneAbove(t, logrus.WarnLevel) fails on errors.
+func (t *Hook) AssertNoneAbove(tb testing.TB, level logrus.Level) {
+	tb.Helper()
+	if level == logrus.PanicLevel {
+		return
+	}
+	above := AtLeast(level - 1)
+	if n := t.Count(above); n > 0 {
+		t.fail(tb, fmt.Sprintf("expected no entry above level %s, found %d", level, n), above)
+	}
+}
+
+// AssertOrder fails the test unless entries matched by each of matchers were
+// logged in this order, other entries possibly logged in between.
+func (t *Hook) AssertOrder(tb testing.TB, matchers ...Matcher) {
+	tb.Helper()
+	next := 0
+	for _, entry := range t.AllEntries() {
+		if next < len(matchers) && matchers[next].Match(entry) {
+			next++
+		}
+	}
+	if next < len(matchers) {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "expected entries in order:\n")
+		for i, m := range matchers {
+			mark := "  "
+			if i == next {
+				mark = "> "
+			}
+			fmt.Fprintf(&b, "  %s%d. %s\n", mark, i+1, m)
+		}
+		fmt.Fprintf(&b, "no entry with %s after the previous ones", matchers[next])
+		t.fail(tb, b.String(), matchers[next])
+	}
+}
+
+// fail reports message along with the logged entries, those matched by m
+// marked with a +.
+func (t *Hook) fail(tb testing.TB, message string, m Matcher) {
+	tb.Helper()
+	var b bytes.Buffer
+	b.WriteString(message)
+	entries := t.AllEntries()
+	if len(entries) == 0 {
+		b.WriteString("\nno entries logged")
+	} else {
+		fmt.Fprintf(&b, "\nentries logged (+ matching %s):", m)
+		for _, entry := range entries {
+			mark := " "
+			if m.Match(entry) {
+				mark = "+"
+			}
+			fmt.Fprintf(&b, "\n  %s %s", mark, formatEntry(entry))
+		}
+	}
+	tb.Error(b.String())
+}
+
+// formatEntry describes an entry on a single line, such as
+// `[warning] "A walrus appears" animal=walrus size=10`.
+func formatEntry(entry *logrus.Entry) string {
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[%s] %q", entry.Level, entry.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, entry.Data[k])
+	}
+	return b.String()
+}
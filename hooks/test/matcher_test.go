@@ -0,0 +1,109 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the failures of assertions, instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func newMatcherLogger() (*logrus.Logger, *Hook) {
+	logger, hook := NewNullLogger()
+	logger.Level = logrus.DebugLevel
+	logger.WithField("animal", "walrus").Debug("Walrus spotted")
+	logger.WithFields(logrus.Fields{"animal": "walrus", "size": 10}).Warn("A walrus appears")
+	logger.WithError(errors.New("tusk broken")).Error("Walrus hurt")
+	return logger, hook
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+	_, hook := newMatcherLogger()
+
+	assert.Len(hook.Find(), 3)
+	assert.Len(hook.Find(AtLevel(logrus.WarnLevel, logrus.ErrorLevel)), 2)
+	assert.Len(hook.Find(AtLeast(logrus.WarnLevel)), 2)
+	assert.Len(hook.Find(AtLeast(logrus.ErrorLevel)), 1)
+	assert.Len(hook.Find(Message("A walrus appears")), 1)
+	assert.Len(hook.Find(MessageContains("Walrus")), 2)
+	assert.Len(hook.Find(MessageMatches(`(?i)^a walrus`)), 1)
+	assert.Len(hook.Find(HasField("animal")), 2)
+	assert.Len(hook.Find(Field("animal", "walrus"), Field("size", 10)), 1)
+	assert.Len(hook.Find(Field("size", int64(10))), 0)
+	assert.Len(hook.Find(Field(logrus.ErrorKey, "tusk broken")), 1)
+	assert.Len(hook.Find(FieldFunc("size", func(v interface{}) bool { return v.(int) > 5 })), 1)
+	assert.Len(hook.Find(Any(AtLevel(logrus.DebugLevel), HasField("error"))), 2)
+	assert.Len(hook.Find(Not(HasField("animal"))), 1)
+
+	found := hook.Find(AtLevel(logrus.WarnLevel))
+	assert.Equal("A walrus appears", found[0].Message)
+	assert.Equal(2, hook.Count(HasField("animal")))
+}
+
+func TestAssertionsPass(t *testing.T) {
+	_, hook := newMatcherLogger()
+	r := &recorder{TB: t}
+
+	entry := hook.AssertLogged(r, AtLevel(logrus.WarnLevel), Field("size", 10))
+	assert.Equal(t, "A walrus appears", entry.Message)
+	hook.AssertNotLogged(r, AtLevel(logrus.InfoLevel))
+	hook.AssertCount(r, 2, HasField("animal"))
+	hook.AssertNoneAbove(r, logrus.ErrorLevel)
+	hook.AssertOrder(r, MessageContains("spotted"), MessageContains("hurt"))
+	hook.AssertOrder(r)
+
+	assert.Empty(t, r.errors)
+}
+
+func TestAssertionsFail(t *testing.T) {
+	_, hook := newMatcherLogger()
+	r := &recorder{TB: t}
+
+	assert.Nil(t, hook.AssertLogged(r, Message("A walrus leaves")))
+	hook.AssertNotLogged(r, HasField("size"))
+	hook.AssertCount(r, 1, HasField("animal"))
+	hook.AssertNoneAbove(r, logrus.WarnLevel)
+	hook.AssertOrder(r, MessageContains("hurt"), MessageContains("spotted"))
+
+	if assert.Len(t, r.errors, 5) {
+		assert.Equal(t, `expected an entry with message "A walrus leaves", found none
+entries logged (+ matching message "A walrus leaves"):
+    [debug] "Walrus spotted" animal=walrus
+    [warning] "A walrus appears" animal=walrus size=10
+    [error] "Walrus hurt" error=tusk broken`, r.errors[0])
+		assert.Equal(t, `expected no entry with field "size", found 1
+entries logged (+ matching field "size"):
+    [debug] "Walrus spotted" animal=walrus
+  + [warning] "A walrus appears" animal=walrus size=10
+    [error] "Walrus hurt" error=tusk broken`, r.errors[1])
+		assert.Contains(t, r.errors[2], `expected 1 entries with field "animal", found 2`)
+		assert.Contains(t, r.errors[3], "expected no entry above level warning, found 1")
+		assert.Contains(t, r.errors[3], `+ [error] "Walrus hurt"`)
+		assert.Contains(t, r.errors[4], `expected entries in order:
+    1. message containing "hurt"
+  > 2. message containing "spotted"
+no entry with message containing "spotted" after the previous ones`)
+	}
+}
+
+func TestAssertionsNoEntries(t *testing.T) {
+	_, hook := NewNullLogger()
+	r := &recorder{TB: t}
+
+	hook.AssertLogged(r, AtLevel(logrus.InfoLevel))
+	if assert.Len(t, r.errors, 1) {
+		assert.Equal(t, "expected an entry with level info, found none\nno entries logged", r.errors[0])
+	}
+}
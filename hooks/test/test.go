@@ -17,6 +17,14 @@ type Hook struct {
 	// value directly.
 	Entries []*logrus.Entry
 	mu      sync.RWMutex
+
+	// changed is closed and replaced when an entry is fired, to wake up
+	// WaitFor.
+	changed     chan struct{}
+	subscribers []*subscription
+	// offerMu keeps the entries offered to subscribers in order, without
+	// holding mu while matching them.
+	offerMu sync.Mutex
 }
 
 // NewGlobal installs a test hook for the global logger.
@@ -49,10 +57,29 @@ func NewNullLogger() (*logrus.Logger, *Hook) {
 
 }
 
+// Fire records a copy of e, as it is when the hook fires. Changes the
+// logger or the following hooks make to e afterwards, such as setting its
+// Buffer, aren't seen in Entries.
 func (t *Hook) Fire(e *logrus.Entry) error {
+	entry := *e
+	e = &entry
+
+	t.offerMu.Lock()
+	defer t.offerMu.Unlock()
+
 	t.mu.Lock()
-	defer t.mu.Unlock()
 	t.Entries = append(t.Entries, e)
+	if t.changed != nil {
+		close(t.changed)
+		t.changed = nil
+	}
+	subscribers := make([]*subscription, len(t.subscribers))
+	copy(subscribers, t.subscribers)
+	t.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.offer(e)
+	}
 	return nil
 }
 
@@ -37,3 +37,14 @@ func TestAllHooks(t *testing.T) {
 	assert.Equal(1, len(hook.Entries))
 
 }
+
+func TestFireKeepsACopy(t *testing.T) {
+	hook := new(Hook)
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "Fired"
+	assert.NoError(t, hook.Fire(entry))
+
+	entry.Message = "Changed after firing"
+	assert.Equal(t, "Fired", hook.LastEntry().Message)
+	assert.Equal(t, "Fired", hook.Entries[0].Message)
+}
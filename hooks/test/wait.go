@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WaitFor blocks until an entry matched by all of matchers has been logged,
+// and returns it. Entries logged before the call are considered too. It
+// returns the error of ctx if ctx is done first.
+//
+// WaitFor is meant for entries logged by other goroutines, such as those
+// of Logger.Writer() or of asynchronous hooks, rather than sleeping before
+// calling LastEntry:
+//
+//   go worker.Run()
+//   entry, err := hook.WaitFor(ctx, test.MessageContains("job done"))
+func (t *Hook) WaitFor(ctx context.Context, matchers ...Matcher) (*logrus.Entry, error) {
+	m := All(matchers...)
+	seen := 0
+	for {
+		t.mu.Lock()
+		if seen > len(t.Entries) {
+			// Reset was called meanwhile
+			seen = 0
+		}
+		// The entries are matched without holding the lock, so that
+		// matchers may use the hook.
+		entries := t.Entries[seen:len(t.Entries):len(t.Entries)]
+		seen = len(t.Entries)
+		if t.changed == nil {
+			t.changed = make(chan struct{})
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		for _, entry := range entries {
+			if m.Match(entry) {
+				// Make a copy, for safety
+				e := *entry
+				return &e, nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WaitForTimeout is like WaitFor, but gives up after timeout.
+func (t *Hook) WaitForTimeout(timeout time.Duration, matchers ...Matcher) (*logrus.Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return t.WaitFor(ctx, matchers...)
+}
+
+// AssertEventually fails the test unless an entry matched by all of matchers
+// is logged within timeout, and returns it.
+func (t *Hook) AssertEventually(tb testing.TB, timeout time.Duration, matchers ...Matcher) *logrus.Entry {
+	tb.Helper()
+	entry, err := t.WaitForTimeout(timeout, matchers...)
+	if err != nil {
+		m := All(matchers...)
+		t.fail(tb, fmt.Sprintf("expected an entry with %s within %v, found none", m, timeout), m)
+	}
+	return entry
+}
+
+// Subscribe returns a channel receiving the entries matched by all of
+// matchers logged from now on, in order. Entries are queued for slow
+// receivers, so logging never blocks on the channel. Calling the returned
+// function stops the subscription and closes the channel.
+//
+//   entries, cancel := hook.Subscribe(test.AtLeast(logrus.ErrorLevel))
+//   defer cancel()
+//   select {
+//   case entry := <-entries:
+//     ...
+//   case <-time.After(time.Second):
+//     t.Fatal("no error logged")
+//   }
+func (t *Hook) Subscribe(matchers ...Matcher) (<-chan *logrus.Entry, func()) {
+	s := &subscription{
+		matcher: All(matchers...),
+		entries: make(chan *logrus.Entry),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+
+	t.mu.Lock()
+	t.subscribers = append(t.subscribers, s)
+	t.mu.Unlock()
+
+	var once sync.Once
+	return s.entries, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			for i, other := range t.subscribers {
+				if other == s {
+					t.subscribers = append(t.subscribers[:i], t.subscribers[i+1:]...)
+					break
+				}
+			}
+			t.mu.Unlock()
+
+			close(s.done)
+			<-s.stopped
+		})
+	}
+}
+
+type subscription struct {
+	matcher Matcher
+
+	mu      sync.Mutex
+	pending []*logrus.Entry
+
+	entries chan *logrus.Entry
+	notify  chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// offer queues a copy of entry if it matches, without blocking.
+func (s *subscription) offer(entry *logrus.Entry) {
+	if !s.matcher.Match(entry) {
+		return
+	}
+	e := *entry
+	s.mu.Lock()
+	s.pending = append(s.pending, &e)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) run() {
+	defer close(s.stopped)
+	defer close(s.entries)
+
+	for {
+		s.mu.Lock()
+		pending := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		for _, entry := range pending {
+			select {
+			case s.entries <- entry:
+			case <-s.done:
+				return
+			}
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.done:
+			return
+		}
+	}
+}
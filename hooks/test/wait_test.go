@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitFor(t *testing.T) {
+	logger, hook := NewNullLogger()
+	logger.Info("Already logged")
+
+	entry, err := hook.WaitForTimeout(time.Second, Message("Already logged"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Already logged", entry.Message)
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			time.Sleep(time.Millisecond)
+			logger.WithField("i", i).Info("Working")
+		}
+		logger.Warn("Done")
+	}()
+
+	entry, err = hook.WaitForTimeout(5*time.Second, AtLevel(logrus.WarnLevel))
+	assert.NoError(t, err)
+	assert.Equal(t, "Done", entry.Message)
+	assert.Equal(t, 5, hook.Count(Message("Working")))
+}
+
+func TestWaitForWriter(t *testing.T) {
+	logger, hook := NewNullLogger()
+	w := logger.Writer()
+	defer w.Close()
+
+	fmt.Fprintln(w, "from the writer")
+	entry, err := hook.WaitForTimeout(5*time.Second, MessageContains("from the writer"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, logrus.InfoLevel, entry.Level)
+	}
+}
+
+// waiting blocks until WaitFor waits for the entries fired next, so that
+// they are logged while it waits.
+func waiting(hook *Hook) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		hook.mu.Lock()
+		waiting := hook.changed != nil
+		hook.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForContext(t *testing.T) {
+	logger, hook := NewNullLogger()
+	logger.Info("Unrelated")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		waiting(hook)
+		logger.Info("Still unrelated")
+		cancel()
+	}()
+	entry, err := hook.WaitFor(ctx, AtLevel(logrus.ErrorLevel))
+	assert.Nil(t, entry)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = hook.WaitForTimeout(10*time.Millisecond, AtLevel(logrus.ErrorLevel))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestWaitForReset(t *testing.T) {
+	logger, hook := NewNullLogger()
+	logger.Info("One")
+	logger.Info("Two")
+
+	go func() {
+		waiting(hook)
+		hook.Reset()
+		logger.Info("Three")
+	}()
+	entry, err := hook.WaitForTimeout(5*time.Second, Message("Three"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Three", entry.Message)
+}
+
+func TestAssertEventually(t *testing.T) {
+	logger, hook := NewNullLogger()
+	r := &recorder{TB: t}
+
+	go logger.Warn("Soon")
+	entry := hook.AssertEventually(r, 5*time.Second, Message("Soon"))
+	assert.Equal(t, "Soon", entry.Message)
+
+	assert.Nil(t, hook.AssertEventually(r, 10*time.Millisecond, Message("Never")))
+	if assert.Len(t, r.errors, 1) {
+		assert.Contains(t, r.errors[0], `expected an entry with message "Never" within 10ms, found none`)
+		assert.Contains(t, r.errors[0], `[warning] "Soon"`)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	logger, hook := NewNullLogger()
+	logger.Error("Before subscribing")
+
+	entries, cancel := hook.Subscribe(AtLeast(logrus.WarnLevel))
+	go func() {
+		for i := 0; i < 100; i++ {
+			logger.Info("Ignored")
+			logger.WithField("i", i).Warn("Warning")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		select {
+		case entry := <-entries:
+			assert.Equal(t, "Warning", entry.Message)
+			assert.Equal(t, i, entry.Data["i"])
+		case <-time.After(5 * time.Second):
+			t.Fatalf("entry %d not received", i)
+		}
+	}
+
+	cancel()
+	cancel()
+	_, ok := <-entries
+	assert.False(t, ok)
+	logger.Warn("After cancelling")
+}
+
+func TestSubscribeSlowReceiver(t *testing.T) {
+	logger, hook := NewNullLogger()
+	entries, cancel := hook.Subscribe()
+	defer cancel()
+
+	// Logging doesn't block on the receiver
+	for i := 0; i < 1000; i++ {
+		logger.WithField("i", i).Info("Queued")
+	}
+	for i := 0; i < 1000; i++ {
+		entry := <-entries
+		assert.Equal(t, i, entry.Data["i"])
+	}
+}
+
+func TestMatchersUsingTheHook(t *testing.T) {
+	logger, hook := NewNullLogger()
+	// Matches the entries logged after another one
+	notFirst := MatcherFunc("not first", func(entry *logrus.Entry) bool {
+		return len(hook.AllEntries()) > 1 && hook.LastEntry() != nil
+	})
+
+	entries, cancel := hook.Subscribe(notFirst)
+	defer cancel()
+	logger.Info("First")
+	logger.Info("Second")
+	select {
+	case entry := <-entries:
+		assert.Equal(t, "Second", entry.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry not received")
+	}
+
+	entry, err := hook.WaitForTimeout(5*time.Second, notFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", entry.Message)
+}
@@ -0,0 +1,310 @@
+package logrus_httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Keys of the fields logged by AccessLog, which CLFFormatter reads unless
+// its FieldMap maps them to other fields.
+const (
+	FieldKeyRequestID = "request_id"
+	FieldKeyMethod    = "method"
+	FieldKeyHost      = "host"
+	FieldKeyPath      = "path"
+	FieldKeyQuery     = "query"
+	FieldKeyProto     = "proto"
+	FieldKeyRoute     = "route"
+	FieldKeyStartTime = "start_time"
+	FieldKeyStatus    = "status"
+	FieldKeyBytes     = "bytes"
+	FieldKeyDuration  = "duration"
+	FieldKeyRemoteIP  = "remote_ip"
+	FieldKeyUser      = "user"
+	FieldKeyReferer   = "referer"
+	FieldKeyUserAgent = "user_agent"
+)
+
+// AccessLogConfig configures AccessLog.
+type AccessLogConfig struct {
+	// Logger logging the requests. Defaults to the standard logger.
+	Logger logrus.FieldLogger
+	// Message of the entries. Defaults to "Request completed".
+	Message string
+	// Level returns the level of the entry of a response with status.
+	// Defaults to DefaultLevel. Levels above ErrorLevel are logged at
+	// ErrorLevel, so that a response never exits or panics.
+	Level func(status int) logrus.Level
+	// TrustedProxies are the IPs or CIDRs of the proxies whose
+	// X-Forwarded-For and X-Real-IP headers are trusted to tell the IP of
+	// the client. None are trusted by default.
+	TrustedProxies []string
+	// RequestIDHeader is the header propagating request IDs, in requests
+	// and responses. Requests without a valid one, up to 64 letters, digits
+	// and "-_.:" characters, get a random ID. Defaults to RequestIDHeader.
+	RequestIDHeader string
+	// Route returns the route the request matched, such as
+	// "/users/{id}", when handlers don't call SetRoute.
+	Route func(r *http.Request) string
+}
+
+// DefaultLevel logs server errors at ErrorLevel, client errors at WarnLevel
+// and the other responses at InfoLevel.
+func DefaultLevel(status int) logrus.Level {
+	switch {
+	case status >= 500:
+		return logrus.ErrorLevel
+	case status >= 400:
+		return logrus.WarnLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+type contextKey int
+
+const (
+	entryKey contextKey = iota
+	routeKey
+)
+
+// FromContext returns the request-scoped entry AccessLog stored in ctx,
+// carrying the request_id, method, path and remote_ip fields, or an entry
+// of the standard logger if there is none.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger()).WithContext(ctx)
+}
+
+// SetRoute sets the route r matched, logged as the route field by AccessLog.
+// Routers or handlers call it once they know which route matched.
+func SetRoute(r *http.Request, route string) {
+	if p, ok := r.Context().Value(routeKey).(*string); ok {
+		*p = route
+	}
+}
+
+// AccessLog returns middleware logging an entry for each request with the
+// fields of the request-scoped entry (see FromContext) and proto, query,
+// route, status, bytes, start_time, duration in seconds, the user of basic
+// authentication, referer and user_agent, at a level depending on the
+// status. The request ID is added to the response headers.
+//
+//   handler, err := logrus_httplog.AccessLog(logrus_httplog.AccessLogConfig{
+//     Logger:         log,
+//     TrustedProxies: []string{"10.0.0.0/8"},
+//   }, logrus_httplog.Recover(logrus_httplog.RecoverConfig{Logger: log}, mux))
+//
+// Requests panicking through the middleware are logged with the status 500.
+// It returns an error if an entry of TrustedProxies is neither an IP nor a
+// CIDR.
+func AccessLog(config AccessLogConfig, next http.Handler) (http.Handler, error) {
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+	if config.Message == "" {
+		config.Message = "Request completed"
+	}
+	if config.Level == nil {
+		config.Level = DefaultLevel
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = RequestIDHeader
+	}
+	trusted, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(config.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(config.RequestIDHeader, requestID)
+
+		var route string
+		entry := config.Logger.WithFields(logrus.Fields{
+			FieldKeyRequestID: requestID,
+			FieldKeyMethod:    r.Method,
+			FieldKeyPath:      r.URL.Path,
+			FieldKeyRemoteIP:  clientIP(r, trusted),
+		})
+		ctx := context.WithValue(r.Context(), routeKey, &route)
+		entry = entry.WithContext(ctx)
+		ctx = context.WithValue(ctx, entryKey, entry)
+		r = r.WithContext(ctx)
+
+		rw := newResponseWriter(w)
+		completed := false
+		defer func() {
+			status := rw.status
+			if !completed && !rw.wroteHeader {
+				// Panicking
+				status = http.StatusInternalServerError
+			}
+			if route == "" && config.Route != nil {
+				route = config.Route(r)
+			}
+			fields := logrus.Fields{
+				FieldKeyProto:     r.Proto,
+				FieldKeyStatus:    status,
+				FieldKeyBytes:     rw.size,
+				FieldKeyStartTime: start,
+				FieldKeyDuration:  time.Since(start).Seconds(),
+			}
+			if r.URL.RawQuery != "" {
+				fields[FieldKeyQuery] = r.URL.RawQuery
+			}
+			if route != "" {
+				fields[FieldKeyRoute] = route
+			}
+			if user := requestUser(r); user != "" {
+				fields[FieldKeyUser] = user
+			}
+			if referer := r.Referer(); referer != "" {
+				fields[FieldKeyReferer] = referer
+			}
+			if userAgent := r.UserAgent(); userAgent != "" {
+				fields[FieldKeyUserAgent] = userAgent
+			}
+			logAt(entry.WithFields(fields), config.Level(status), config.Message)
+		}()
+		next.ServeHTTP(rw, r)
+		completed = true
+	}), nil
+}
+
+// requestUser returns the basic authentication user of r, or its URL user.
+func requestUser(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	if r.URL.User != nil {
+		return r.URL.User.Username()
+	}
+	return ""
+}
+
+// logAt logs message at level, or at ErrorLevel for the levels above it.
+func logAt(entry *logrus.Entry, level logrus.Level, message string) {
+	if level < logrus.ErrorLevel {
+		level = logrus.ErrorLevel
+	}
+	switch level {
+	case logrus.DebugLevel:
+		entry.Debug(message)
+	case logrus.InfoLevel:
+		entry.Info(message)
+	case logrus.WarnLevel:
+		entry.Warn(message)
+	case logrus.ErrorLevel:
+		entry.Error(message)
+	}
+}
+
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("httplog: invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("httplog: invalid trusted proxy %q, %v", proxy, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client of r. When the peer is a trusted
+// proxy, it is the last untrusted address of X-Forwarded-For, or the
+// X-Real-IP header.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !isTrusted(net.ParseIP(remote), trusted) {
+		return remote
+	}
+
+	if len(r.Header["X-Forwarded-For"]) > 0 {
+		hops := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				break
+			}
+			if !isTrusted(ip, trusted) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+	return remote
+}
+
+// validRequestID reports whether id can be propagated and logged: at most
+// 64 ASCII letters, digits and "-_.:" characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
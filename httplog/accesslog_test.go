@@ -0,0 +1,187 @@
+package logrus_httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func accessLog(t *testing.T, config AccessLogConfig, next http.Handler) http.Handler {
+	handler, err := AccessLog(config, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestAccessLog(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := accessLog(t, AccessLogConfig{Logger: logger}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r, "/walrus/{id}")
+		FromContext(r.Context()).WithField("animal", "walrus").Info("Handling")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	r := httptest.NewRequest("POST", "/walrus/42?size=10", nil)
+	r.Header.Set("User-Agent", "walrus/1.0")
+	r.SetBasicAuth("walrus", "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	requestID := w.Header().Get(RequestIDHeader)
+	assert.Len(t, requestID, 32)
+
+	lines := splitEntries(t, buffer)
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	handling, access := lines[0], lines[1]
+	assert.Equal(t, "Handling", handling["msg"])
+	assert.Equal(t, "walrus", handling["animal"])
+	assert.Equal(t, requestID, handling["request_id"])
+	assert.Equal(t, "/walrus/42", handling["path"])
+
+	assert.Equal(t, "info", access["level"])
+	assert.Equal(t, "Request completed", access["msg"])
+	assert.Equal(t, requestID, access["request_id"])
+	assert.Equal(t, "POST", access["method"])
+	assert.Equal(t, "/walrus/42", access["path"])
+	assert.Equal(t, "/walrus/{id}", access["route"])
+	assert.Equal(t, float64(201), access["status"])
+	assert.Equal(t, float64(7), access["bytes"])
+	assert.Equal(t, "192.0.2.1", access["remote_ip"])
+	assert.Equal(t, "walrus/1.0", access["user_agent"])
+	assert.Equal(t, "walrus", access["user"])
+	assert.IsType(t, float64(0), access["duration"])
+	started, err := time.Parse(time.RFC3339Nano, access["start_time"].(string))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), started, time.Minute)
+}
+
+func TestAccessLogLevelsAndRequestID(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := accessLog(t, AccessLogConfig{
+		Logger: logger,
+		Route:  func(r *http.Request) string { return "route" },
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	for _, tc := range []struct {
+		path, requestID, level, loggedID string
+	}{
+		{"/", "propagated-id", "info", "propagated-id"},
+		{"/missing", "", "warning", ""},
+		{"/broken", "bad id", "error", ""},
+		{"/", "<script>", "info", ""},
+		{"/", strings.Repeat("a", 65), "info", ""},
+	} {
+		buffer.Reset()
+		r := httptest.NewRequest("GET", tc.path, nil)
+		if tc.requestID != "" {
+			r.Header.Set(RequestIDHeader, tc.requestID)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		entry := lastEntry(t, buffer)
+		assert.Equal(t, tc.level, entry["level"], tc.path)
+		assert.Equal(t, "route", entry["route"])
+		assert.Equal(t, w.Header().Get(RequestIDHeader), entry["request_id"])
+		if tc.loggedID != "" {
+			assert.Equal(t, tc.loggedID, entry["request_id"])
+		} else {
+			assert.Len(t, entry["request_id"], 32)
+		}
+	}
+}
+
+func TestAccessLogLevelAboveError(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := accessLog(t, AccessLogConfig{
+		Logger: logger,
+		Level:  func(status int) logrus.Level { return logrus.PanicLevel },
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert.NotPanics(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+	assert.Equal(t, "error", lastEntry(t, buffer)["level"])
+}
+
+func TestAccessLogPanic(t *testing.T) {
+	logger, buffer := newLogger()
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("walrus escaped")
+	})
+
+	// Recovered inside: the 500 response is logged
+	handler := accessLog(t, AccessLogConfig{Logger: logger}, Recover(RecoverConfig{Logger: logger}, inner))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	lines := splitEntries(t, buffer)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "Recovered from panic", lines[0]["msg"])
+		assert.Equal(t, lines[0]["request_id"], lines[1]["request_id"])
+		assert.Equal(t, float64(500), lines[1]["status"])
+		assert.Equal(t, "error", lines[1]["level"])
+	}
+
+	// Panicking through: logged while the panic goes on
+	buffer.Reset()
+	handler = accessLog(t, AccessLogConfig{Logger: logger}, inner)
+	assert.Panics(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+	assert.Equal(t, float64(500), lastEntry(t, buffer)["status"])
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		remote, forwarded, realIP, expected string
+	}{
+		{"203.0.113.5:1234", "198.51.100.1", "", "203.0.113.5"},
+		{"192.0.2.1:1234", "198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"192.0.2.1:1234", "198.51.100.1, 198.51.100.2, 10.0.0.2", "", "198.51.100.2"},
+		{"192.0.2.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"192.0.2.1:1234", "garbage", "198.51.100.7", "198.51.100.7"},
+		{"192.0.2.1:1234", "", "198.51.100.7", "198.51.100.7"},
+		{"192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"10.1.2.3", "198.51.100.1", "", "198.51.100.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		assert.Equal(t, tc.expected, clientIP(r, trusted), "%+v", tc)
+	}
+
+	_, err = AccessLog(AccessLogConfig{TrustedProxies: []string{"walrus"}}, http.NotFoundHandler())
+	assert.EqualError(t, err, `httplog: invalid trusted proxy "walrus"`)
+	_, err = parseTrustedProxies([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+}
+
+func TestFromContextDefault(t *testing.T) {
+	entry := FromContext(httptest.NewRequest("GET", "/", nil).Context())
+	assert.Equal(t, logrus.StandardLogger(), entry.Logger)
+}
@@ -0,0 +1,326 @@
+package logrus_httplog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Patterns of the Apache Common and Combined Log Formats.
+const (
+	CommonLogFormat   = `%h %l %u %t "%r" %>s %b`
+	CombinedLogFormat = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`
+)
+
+// CLFTimestampFormat is the format of the %t directive.
+const CLFTimestampFormat = "02/Jan/2006:15:04:05 -0700"
+
+// FieldMap maps the keys of the fields CLFFormatter reads, the FieldKey
+// constants, to the fields entries actually carry.
+type FieldMap map[string]string
+
+func (f FieldMap) resolve(key string) string {
+	if k, ok := f[key]; ok {
+		return k
+	}
+	return key
+}
+
+// CLFFormatter formats entries carrying HTTP request fields, such as those
+// logged by AccessLog, in the Apache Common or Combined Log Format, or a
+// custom pattern of Apache directives:
+//
+//   %h, %a      remote IP
+//   %l          remote logname, always -
+//   %u          remote user, of basic authentication
+//   %t          time the request started, or of the entry when unknown,
+//               as [02/Jan/2006:15:04:05 -0700]
+//   %r          request line, such as GET /walrus?size=10 HTTP/1.1
+//   %m, %U, %q  method, path, and query string prefixed with ?
+//   %H          protocol
+//   %s, %>s     status
+//   %b, %B      bytes of the response, - or 0 when none
+//   %D, %T      duration in microseconds or seconds
+//   %{Name}i    request header: Referer, User-Agent and X-Request-Id are
+//               read from referer, user_agent and request_id, the others
+//               from the header name lower-cased with _ instead of -
+//   %%          a %
+//
+// Missing fields show as -. The entries of another logger than the one of
+// the application are usually formatted with it, as CLF has no room for
+// their message.
+type CLFFormatter struct {
+	// Pattern of the output. Defaults to CombinedLogFormat.
+	Pattern string
+
+	// FieldMap allows reading fields of other names than those of
+	// AccessLog. As an example:
+	//   formatter := &CLFFormatter{
+	//     FieldMap: FieldMap{
+	//       FieldKeyRemoteIP: "client",
+	//     },
+	//   }
+	FieldMap FieldMap
+
+	once       sync.Once
+	directives []clfDirective
+	err        error
+}
+
+type clfDirective struct {
+	literal string
+	verb    byte
+	arg     string
+}
+
+func parseCLFPattern(pattern string) ([]clfDirective, error) {
+	var directives []clfDirective
+	for len(pattern) > 0 {
+		i := strings.IndexByte(pattern, '%')
+		if i < 0 {
+			directives = append(directives, clfDirective{literal: pattern})
+			break
+		}
+		if i > 0 {
+			directives = append(directives, clfDirective{literal: pattern[:i]})
+		}
+		pattern = pattern[i+1:]
+
+		var d clfDirective
+		if len(pattern) > 0 && (pattern[0] == '>' || pattern[0] == '<') {
+			pattern = pattern[1:]
+		}
+		if len(pattern) > 0 && pattern[0] == '{' {
+			end := strings.IndexByte(pattern, '}')
+			if end < 0 {
+				return nil, fmt.Errorf("httplog: unterminated %%{ in pattern")
+			}
+			d.arg = pattern[1:end]
+			pattern = pattern[end+1:]
+		}
+		if len(pattern) == 0 {
+			return nil, fmt.Errorf("httplog: missing directive after %% in pattern")
+		}
+		d.verb = pattern[0]
+		pattern = pattern[1:]
+
+		switch d.verb {
+		case '%':
+			d = clfDirective{literal: "%"}
+		case 'h', 'a', 'l', 'u', 't', 'r', 'm', 'U', 'q', 'H', 's', 'b', 'B', 'D', 'T':
+			if d.arg != "" {
+				return nil, fmt.Errorf("httplog: unsupported directive %%{%s}%c in pattern", d.arg, d.verb)
+			}
+		case 'i':
+			if d.arg == "" {
+				return nil, fmt.Errorf("httplog: missing header name in %%i directive")
+			}
+		default:
+			return nil, fmt.Errorf("httplog: unknown directive %%%c in pattern", d.verb)
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// Format renders a single entry as a line of the pattern.
+func (f *CLFFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	f.once.Do(func() {
+		pattern := f.Pattern
+		if pattern == "" {
+			pattern = CombinedLogFormat
+		}
+		f.directives, f.err = parseCLFPattern(pattern)
+	})
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+	for _, d := range f.directives {
+		if d.verb == 0 {
+			b.WriteString(d.literal)
+			continue
+		}
+		f.appendDirective(b, entry, d)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+func (f *CLFFormatter) field(entry *logrus.Entry, key string) (interface{}, bool) {
+	v, ok := entry.Data[f.FieldMap.resolve(key)]
+	if !ok || v == nil {
+		return nil, false
+	}
+	if s, isString := v.(string); isString && s == "" {
+		return nil, false
+	}
+	return v, true
+}
+
+func (f *CLFFormatter) appendField(b *bytes.Buffer, entry *logrus.Entry, key string) {
+	if v, ok := f.field(entry, key); ok {
+		appendEscaped(b, fmt.Sprint(v))
+	} else {
+		b.WriteByte('-')
+	}
+}
+
+func (f *CLFFormatter) appendDirective(b *bytes.Buffer, entry *logrus.Entry, d clfDirective) {
+	switch d.verb {
+	case 'h', 'a':
+		f.appendField(b, entry, FieldKeyRemoteIP)
+	case 'l':
+		b.WriteByte('-')
+	case 'u':
+		f.appendField(b, entry, FieldKeyUser)
+	case 't':
+		b.WriteByte('[')
+		b.WriteString(f.startTime(entry).Format(CLFTimestampFormat))
+		b.WriteByte(']')
+	case 'r':
+		method, hasMethod := f.field(entry, FieldKeyMethod)
+		path, hasPath := f.field(entry, FieldKeyPath)
+		if !hasMethod || !hasPath {
+			b.WriteByte('-')
+			return
+		}
+		line := fmt.Sprintf("%v %v", method, path)
+		if query, ok := f.field(entry, FieldKeyQuery); ok {
+			line += fmt.Sprintf("?%v", query)
+		}
+		if proto, ok := f.field(entry, FieldKeyProto); ok {
+			line += fmt.Sprintf(" %v", proto)
+		}
+		appendEscaped(b, line)
+	case 'm':
+		f.appendField(b, entry, FieldKeyMethod)
+	case 'U':
+		f.appendField(b, entry, FieldKeyPath)
+	case 'q':
+		if query, ok := f.field(entry, FieldKeyQuery); ok {
+			b.WriteByte('?')
+			appendEscaped(b, fmt.Sprint(query))
+		}
+	case 'H':
+		f.appendField(b, entry, FieldKeyProto)
+	case 's':
+		f.appendField(b, entry, FieldKeyStatus)
+	case 'b', 'B':
+		n, ok := f.number(entry, FieldKeyBytes)
+		if n == 0 || !ok {
+			if d.verb == 'b' {
+				b.WriteByte('-')
+			} else {
+				b.WriteByte('0')
+			}
+			return
+		}
+		b.WriteString(strconv.FormatInt(int64(n), 10))
+	case 'D', 'T':
+		seconds, ok := f.duration(entry)
+		if !ok {
+			b.WriteByte('-')
+		} else if d.verb == 'D' {
+			b.WriteString(strconv.FormatInt(int64(seconds*1e6), 10))
+		} else {
+			b.WriteString(strconv.FormatInt(int64(seconds), 10))
+		}
+	case 'i':
+		f.appendField(b, entry, headerFieldKey(d.arg))
+	}
+}
+
+// number returns the value of the numeric field key.
+func (f *CLFFormatter) number(entry *logrus.Entry, key string) (float64, bool) {
+	v, ok := f.field(entry, key)
+	if !ok {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// duration returns the duration field in seconds, from a number of seconds
+// or a time.Duration.
+func (f *CLFFormatter) duration(entry *logrus.Entry) (float64, bool) {
+	if v, ok := f.field(entry, FieldKeyDuration); ok {
+		if d, isDuration := v.(time.Duration); isDuration {
+			return d.Seconds(), true
+		}
+	}
+	return f.number(entry, FieldKeyDuration)
+}
+
+// startTime returns the start_time field, from a time.Time or an RFC 3339
+// string, or the time of entry.
+func (f *CLFFormatter) startTime(entry *logrus.Entry) time.Time {
+	if v, ok := f.field(entry, FieldKeyStartTime); ok {
+		switch v := v.(type) {
+		case time.Time:
+			return v
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t
+			}
+		}
+	}
+	return entry.Time
+}
+
+// headerFieldKey returns the key of the field holding the request header
+// name.
+func headerFieldKey(name string) string {
+	key := strings.ToLower(strings.Replace(name, "-", "_", -1))
+	switch key {
+	case "referer", "referrer":
+		return FieldKeyReferer
+	case "user_agent":
+		return FieldKeyUserAgent
+	case "x_request_id":
+		return FieldKeyRequestID
+	}
+	return key
+}
+
+// appendEscaped writes s escaping quotes, backslashes and non-printable
+// characters like Apache does.
+func appendEscaped(b *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
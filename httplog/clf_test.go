@@ -0,0 +1,116 @@
+package logrus_httplog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var clfTime = time.Date(2017, time.February, 3, 4, 5, 6, 0, time.FixedZone("", -7*3600))
+
+func clfEntry(fields logrus.Fields) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+	entry.Time = clfTime
+	return entry
+}
+
+func TestCLFFormatter(t *testing.T) {
+	fields := logrus.Fields{
+		FieldKeyRemoteIP:  "192.0.2.1",
+		FieldKeyMethod:    "GET",
+		FieldKeyPath:      "/walrus",
+		FieldKeyQuery:     "size=10",
+		FieldKeyProto:     "HTTP/1.1",
+		FieldKeyStatus:    200,
+		FieldKeyBytes:     int64(2326),
+		FieldKeyDuration:  0.0123,
+		FieldKeyReferer:   "http://example.com/",
+		FieldKeyUserAgent: `Walrus "browser"`,
+		FieldKeyRequestID: "abc123",
+	}
+
+	for _, tc := range []struct {
+		pattern, expected string
+	}{
+		{CommonLogFormat, `192.0.2.1 - - [03/Feb/2017:04:05:06 -0700] "GET /walrus?size=10 HTTP/1.1" 200 2326`},
+		{"", `192.0.2.1 - - [03/Feb/2017:04:05:06 -0700] "GET /walrus?size=10 HTTP/1.1" 200 2326 "http://example.com/" "Walrus \"browser\""`},
+		{`%a %m %U%q %H %s %B %D %T %{X-Request-Id}i 100%%`, `192.0.2.1 GET /walrus?size=10 HTTP/1.1 200 2326 12300 0 abc123 100%`},
+	} {
+		f := &CLFFormatter{Pattern: tc.pattern}
+		b, err := f.Format(clfEntry(fields))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected+"\n", string(b), tc.pattern)
+	}
+}
+
+func TestCLFFormatterMissingFields(t *testing.T) {
+	f := &CLFFormatter{Pattern: CommonLogFormat + ` %B %D %q "%{Referer}i"`}
+	b, err := f.Format(clfEntry(logrus.Fields{FieldKeyBytes: 0}))
+	assert.NoError(t, err)
+	assert.Equal(t, `- - - [03/Feb/2017:04:05:06 -0700] "-" - - 0 -  "-"`+"\n", string(b))
+}
+
+func TestCLFFormatterFieldMap(t *testing.T) {
+	f := &CLFFormatter{
+		Pattern: `%h %u "%r" %s %b %T %{X-Forwarded-Proto}i`,
+		FieldMap: FieldMap{
+			FieldKeyRemoteIP: "client",
+			FieldKeyMethod:   "verb",
+			FieldKeyPath:     "url",
+			FieldKeyStatus:   "code",
+			FieldKeyDuration: "took",
+		},
+	}
+	b, err := f.Format(clfEntry(logrus.Fields{
+		"client":            "198.51.100.7",
+		FieldKeyUser:        "walrus",
+		"verb":              "PUT",
+		"url":               "/ice\n",
+		"code":              "204",
+		FieldKeyBytes:       "15",
+		"took":              2500 * time.Millisecond,
+		"x_forwarded_proto": "https",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, `198.51.100.7 walrus "PUT /ice\x0a" 204 15 2 https`+"\n", string(b))
+}
+
+func TestCLFFormatterStartTime(t *testing.T) {
+	f := &CLFFormatter{Pattern: "%t"}
+	started := clfTime.Add(-time.Minute)
+	for _, v := range []interface{}{started, started.Format(time.RFC3339Nano)} {
+		b, err := f.Format(clfEntry(logrus.Fields{FieldKeyStartTime: v}))
+		assert.NoError(t, err)
+		assert.Equal(t, "[03/Feb/2017:04:04:06 -0700]\n", string(b), "%#v", v)
+	}
+}
+
+func TestCLFFormatterInvalidPattern(t *testing.T) {
+	for _, pattern := range []string{"%z", "%", "%{Referer", "%{x}h", "%i"} {
+		_, err := (&CLFFormatter{Pattern: pattern}).Format(clfEntry(nil))
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestCLFFormatterAccessLog(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &CLFFormatter{}
+
+	handler := accessLog(t, AccessLogConfig{Logger: logger}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("walrus"))
+	}))
+	r := httptest.NewRequest("GET", "/walrus?size=10", nil)
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "walrus/1.0")
+	r.SetBasicAuth("walrus", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Regexp(t, `^192\.0\.2\.1 - walrus \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [+-]\d{4}\] "GET /walrus\?size=10 HTTP/1\.1" 200 6 "http://example\.com/" "walrus/1\.0"\n$`, buffer.String())
+}
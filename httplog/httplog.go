@@ -0,0 +1,144 @@
+// Package logrus_httplog provides net/http middleware logging through
+// logrus.
+package logrus_httplog
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header whose value is logged as the request_id
+// field, when the request has a valid one.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestFields returns the fields describing r, named like those of
+// AccessLog: method, host, path, query, proto, remote_ip, and user_agent and
+// request_id when set. Proxies aren't trusted, remote_ip is the peer's.
+func RequestFields(r *http.Request) logrus.Fields {
+	fields := logrus.Fields{
+		FieldKeyMethod:   r.Method,
+		FieldKeyHost:     r.Host,
+		FieldKeyPath:     r.URL.Path,
+		FieldKeyProto:    r.Proto,
+		FieldKeyRemoteIP: clientIP(r, nil),
+	}
+	if r.URL.RawQuery != "" {
+		fields[FieldKeyQuery] = r.URL.RawQuery
+	}
+	if userAgent := r.UserAgent(); userAgent != "" {
+		fields[FieldKeyUserAgent] = userAgent
+	}
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		fields[FieldKeyRequestID] = id
+	}
+	return fields
+}
+
+// RecoverConfig configures Recover.
+type RecoverConfig struct {
+	// Logger logging the panics. Defaults to the standard logger.
+	Logger *logrus.Logger
+	// Level the panics are logged at. Defaults to ErrorLevel, as the zero
+	// value is PanicLevel which would panic again.
+	Level logrus.Level
+	// Repanic panics again with the recovered value after logging it,
+	// instead of responding with 500 Internal Server Error.
+	Repanic bool
+}
+
+// Recover returns middleware recovering the panics of next, and logging
+// them with the request fields, those of the request-scoped entry of
+// AccessLog, the panic value and the stack, see Entry.LogPanic. Unless
+// Repanic is set, it responds with 500 Internal Server Error if next didn't
+// write the header yet.
+//
+//   http.ListenAndServe(":8080", logrus_httplog.Recover(logrus_httplog.RecoverConfig{}, mux))
+//
+// The http.ErrAbortHandler panics used to abort responses aren't logged.
+func Recover(config RecoverConfig, next http.Handler) http.Handler {
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+	if config.Level == logrus.PanicLevel {
+		config.Level = logrus.ErrorLevel
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			// The fields of the request-scoped entry win, as AccessLog
+			// may have trusted proxies for remote_ip.
+			entry := config.Logger.WithContext(r.Context()).WithFields(RequestFields(r))
+			if scoped, ok := r.Context().Value(entryKey).(*logrus.Entry); ok {
+				entry = entry.WithFields(scoped.Data)
+			}
+			entry.LogPanic(value, config.Level)
+			if config.Repanic {
+				panic(value)
+			}
+			if !rw.wroteHeader {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// responseWriter records the status and the size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, when the wrapped writer does.
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, when the wrapped writer does.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("httplog: response writer doesn't support hijacking")
+	}
+	w.wroteHeader = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,122 @@
+package logrus_httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogger() (*logrus.Logger, *bytes.Buffer) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buffer
+	logger.Formatter = &logrus.JSONFormatter{}
+	return logger, &buffer
+}
+
+func splitEntries(t *testing.T, buffer *bytes.Buffer) []logrus.Fields {
+	var entries []logrus.Fields
+	for _, line := range bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n")) {
+		fields := make(logrus.Fields)
+		assert.NoError(t, json.Unmarshal(line, &fields))
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+func lastEntry(t *testing.T, buffer *bytes.Buffer) logrus.Fields {
+	entries := splitEntries(t, buffer)
+	return entries[len(entries)-1]
+}
+
+func TestRequestFields(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/walrus?size=10", nil)
+	r.Header.Set("User-Agent", "walrus/1.0")
+	r.Header.Set(RequestIDHeader, "abc123")
+
+	assert.Equal(t, logrus.Fields{
+		"method":     "GET",
+		"host":       "example.com",
+		"path":       "/walrus",
+		"query":      "size=10",
+		"proto":      "HTTP/1.1",
+		"remote_ip":  "192.0.2.1",
+		"user_agent": "walrus/1.0",
+		"request_id": "abc123",
+	}, RequestFields(r))
+
+	r.Header.Set(RequestIDHeader, "bad id")
+	assert.NotContains(t, RequestFields(r), "request_id")
+}
+
+func TestRecover(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := Recover(RecoverConfig{Logger: logger}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("walrus escaped")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/walrus", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	entry := lastEntry(t, buffer)
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "Recovered from panic", entry["msg"])
+	assert.Equal(t, "walrus escaped", entry["panic"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/walrus", entry["path"])
+	assert.Contains(t, entry["stack"], "httplog.TestRecover.func1")
+}
+
+func TestRecoverStartedResponse(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := Recover(RecoverConfig{Logger: logger, Level: logrus.WarnLevel}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("walrus escaped")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/walrus", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Equal(t, "warning", lastEntry(t, buffer)["level"])
+}
+
+func TestRecoverRepanic(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := Recover(RecoverConfig{Logger: logger, Repanic: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("walrus escaped")
+	}))
+
+	assert.PanicsWithValue(t, "walrus escaped", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/walrus", nil))
+	})
+	assert.Equal(t, "walrus escaped", lastEntry(t, buffer)["panic"])
+}
+
+func TestRecoverAbortAndPanicEntry(t *testing.T) {
+	logger, buffer := newLogger()
+	handler := Recover(RecoverConfig{Logger: logger}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		logger.WithField("animal", "walrus").Panic("The ice breaks!")
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	})
+	assert.Equal(t, 0, buffer.Len())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/ice", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 1, bytes.Count(buffer.Bytes(), []byte("\n")))
+	assert.Equal(t, "The ice breaks!", lastEntry(t, buffer)["msg"])
+}
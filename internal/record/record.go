@@ -0,0 +1,56 @@
+// Package record builds the maps the binary formatters and hooks encode for
+// entries, and splits the decoded maps back into entries.
+package record
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Map returns a map holding the same keys as the output of
+// `logrus.JSONFormatter`: `time`, `level`, `msg` and the fields of entry.
+// Fields clashing with those keys are prefixed with `fields.`.
+func Map(entry *logrus.Entry) map[string]interface{} {
+	data := make(map[string]interface{}, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	SetField(data, "time", entry.Time)
+	SetField(data, "msg", entry.Message)
+	SetField(data, "level", entry.Level.String())
+	return data
+}
+
+// SetField sets k to v in data, mirroring the clash handling of the logrus
+// formatters: a value already set for k is moved to `fields.k`.
+func SetField(data map[string]interface{}, k string, v interface{}) {
+	if oldV, has := data[k]; has {
+		SetField(data, "fields."+k, oldV)
+	}
+	data[k] = v
+}
+
+// Split returns the time, level and message held by a map built by Map once
+// decoded, and the remaining fields.
+func Split(m map[string]interface{}) (t time.Time, level logrus.Level, msg string, data logrus.Fields, err error) {
+	data = make(logrus.Fields, len(m))
+	for k, v := range m {
+		data[k] = v
+	}
+	if v, ok := m["time"].(time.Time); ok {
+		t = v
+		delete(data, "time")
+	}
+	if v, ok := m["msg"].(string); ok {
+		msg = v
+		delete(data, "msg")
+	}
+	if v, ok := m["level"].(string); ok {
+		if level, err = logrus.ParseLevel(v); err != nil {
+			return
+		}
+		delete(data, "level")
+	}
+	return
+}
@@ -1,8 +1,10 @@
 package logrus
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"sync"
 )
 
 type fieldKey string
@@ -22,6 +24,20 @@ func (f FieldMap) resolve(key fieldKey) string {
 	return string(key)
 }
 
+// JSONLayout selects how JSONFormatter lays out the JSON it outputs.
+type JSONLayout int
+
+const (
+	// JSONCompact is the default, single line layout without any whitespace,
+	// meant for production and log shippers.
+	JSONCompact JSONLayout = iota
+	// JSONIndented spreads each entry over several indented lines.
+	JSONIndented
+	// JSONCompactPretty keeps each entry on a single line, with a space after
+	// every colon and comma.
+	JSONCompactPretty
+)
+
 type JSONFormatter struct {
 	// TimestampFormat sets the format used for marshaling timestamps.
 	TimestampFormat string
@@ -40,19 +56,66 @@ type JSONFormatter struct {
 	//    },
 	// }
 	FieldMap FieldMap
+
+	// DataKey allows users to put all the user fields (those set with
+	// `WithField` and `WithFields`) under a single nested object with this
+	// key, instead of merging them with `time`, `msg` and `level` at the top
+	// level. It's omitted for entries without user fields.
+	DataKey string
+
+	// ExpandDottedKeys turns user fields with dotted keys, such as
+	// `http.status`, into nested objects: `{"http": {"status": 200}}`.
+	ExpandDottedKeys bool
+
+	// Layout selects a human friendly layout for local development. Whatever
+	// the layout, the same keys and values are output.
+	Layout JSONLayout
+
+	// Set to true to bypass checking for a TTY before coloring the keys of
+	// the JSONIndented and JSONCompactPretty layouts. JSONCompact output is
+	// never colored.
+	ForceColors bool
+
+	// Force disabling colors.
+	DisableColors bool
+
+	// Whether the logger's out is to a terminal
+	isTerminal bool
+
+	once sync.Once
+}
+
+func (f *JSONFormatter) init(entry *Entry) {
+	if entry.Logger != nil {
+		f.isTerminal = IsTerminal(entry.Logger.Out)
+	}
 }
 
 func (f *JSONFormatter) Format(entry *Entry) ([]byte, error) {
-	data := make(Fields, len(entry.Data)+len(f.FixedFields)+3)
+	data := make(Fields, len(entry.Data)+len(f.FixedFields)+4)
+	fields := data
+	if f.DataKey != "" {
+		fields = make(Fields, len(entry.Data))
+	}
 	for k, v := range entry.Data {
 		switch v := v.(type) {
 		case error:
 			// Otherwise errors are ignored by `encoding/json`
 			// https://github.com/Sirupsen/logrus/issues/137
-			setField(data, k, v.Error())
+			setField(fields, k, v.Error())
 		default:
-			setField(data, k, v)
+			setField(fields, k, v)
+		}
+	}
+	if f.ExpandDottedKeys {
+		fields = expandDottedKeys(fields)
+	}
+	if f.DataKey != "" {
+		if len(fields) > 0 {
+			setField(data, f.DataKey, fields)
 		}
+	} else {
+		data = fields
 	}
 
 	timestampFormat := f.TimestampFormat
@@ -79,5 +142,77 @@ func (f *JSONFormatter) Format(entry *Entry) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal fields to JSON, %v", err)
 	}
-	return append(serialized, '\n'), nil
+	if f.Layout == JSONCompact {
+		return append(serialized, '\n'), nil
+	}
+
+	f.once.Do(func() { f.init(entry) })
+	isColored := (f.ForceColors || f.isTerminal) && !f.DisableColors
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+	prettyJSON(b, serialized, f.Layout == JSONIndented, isColored)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// prettyJSON re-lays out compact JSON, either indented or on a single line
+// with spaces, optionally coloring the object keys.
+func prettyJSON(b *bytes.Buffer, src []byte, indent bool, colored bool) {
+	depth := 0
+	newline := func() {
+		if indent {
+			b.WriteByte('\n')
+			for i := 0; i < depth; i++ {
+				b.WriteString("  ")
+			}
+		}
+	}
+
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			end := i + 1
+			for ; end < len(src) && src[end] != '"'; end++ {
+				if src[end] == '\\' {
+					end++
+				}
+			}
+			str := src[i : end+1]
+			if colored && end+1 < len(src) && src[end+1] == ':' {
+				fmt.Fprintf(b, "\x1b[%dm%s\x1b[0m", blue, str)
+			} else {
+				b.Write(str)
+			}
+			i = end
+		case '{', '[':
+			b.WriteByte(c)
+			if i+1 < len(src) && (src[i+1] == '}' || src[i+1] == ']') {
+				b.WriteByte(src[i+1])
+				i++
+				continue
+			}
+			depth++
+			newline()
+		case '}', ']':
+			depth--
+			newline()
+			b.WriteByte(c)
+		case ',':
+			b.WriteByte(c)
+			if indent {
+				newline()
+			} else {
+				b.WriteByte(' ')
+			}
+		case ':':
+			b.WriteString(": ")
+		default:
+			b.WriteByte(c)
+		}
+	}
 }
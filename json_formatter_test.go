@@ -5,6 +5,9 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestErrorNotLost(t *testing.T) {
@@ -172,3 +175,112 @@ func TestFieldClashWithFixedFields(t *testing.T) {
 		t.Fatalf("fields.fields.msg not set to original value, 'something else'")
 	}
 }
+
+func TestJSONLayouts(t *testing.T) {
+	entry := WithFields(Fields{"animal": "walrus", "tags": []string{}, "nested": map[string]interface{}{"a": "x\"y"}})
+	entry.Time = time.Date(2017, 5, 6, 8, 48, 33, 0, time.UTC)
+	entry.Message = "A walrus appears"
+
+	compact, err := (&JSONFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+
+	indented, err := (&JSONFormatter{Layout: JSONIndented}).Format(entry)
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+	expected := `{
+  "animal": "walrus",
+  "level": "panic",
+  "msg": "A walrus appears",
+  "nested": {
+    "a": "x\"y"
+  },
+  "tags": [],
+  "time": "2017-05-06T08:48:33Z"
+}
+`
+	if string(indented) != expected {
+		t.Fatalf("unexpected indented output:\n%s", indented)
+	}
+
+	pretty, err := (&JSONFormatter{Layout: JSONCompactPretty}).Format(entry)
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+	if strings.Count(string(pretty), "\n") != 1 || !strings.Contains(string(pretty), `{"animal": "walrus", "level": "panic"`) {
+		t.Fatalf("unexpected compact pretty output: %s", pretty)
+	}
+
+	for _, b := range [][]byte{indented, pretty} {
+		var got, want map[string]interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal("Unable to unmarshal formatted entry: ", err)
+		}
+		json.Unmarshal(compact, &want)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestJSONLayoutColors(t *testing.T) {
+	formatter := &JSONFormatter{Layout: JSONCompactPretty, ForceColors: true}
+	b, err := formatter.Format(WithField("animal", "walrus:blue"))
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+	if !strings.Contains(string(b), "\x1b[34m\"animal\"\x1b[0m: \"walrus:blue\"") {
+		t.Fatalf("expected colored key: %q", b)
+	}
+
+	formatter = &JSONFormatter{ForceColors: true}
+	b, _ = formatter.Format(WithField("animal", "walrus"))
+	if strings.Contains(string(b), "\x1b[") {
+		t.Fatalf("compact output must never be colored: %q", b)
+	}
+}
+
+func TestJSONDataKey(t *testing.T) {
+	formatter := &JSONFormatter{DataKey: "fields"}
+	b, err := formatter.Format(WithFields(Fields{"level": 1, "http.status": 200, "err": errors.New("wild walrus")}))
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatal("Unable to unmarshal formatted entry: ", err)
+	}
+	assert.Equal(t, "panic", entry["level"])
+	assert.NotContains(t, entry, "fields.level")
+	assert.Equal(t, map[string]interface{}{
+		"level":       float64(1),
+		"http.status": float64(200),
+		"err":         "wild walrus",
+	}, entry["fields"])
+
+	b, _ = formatter.Format(WithFields(Fields{}))
+	entry = make(map[string]interface{})
+	json.Unmarshal(b, &entry)
+	assert.NotContains(t, entry, "fields")
+}
+
+func TestJSONExpandDottedKeys(t *testing.T) {
+	formatter := &JSONFormatter{DataKey: "fields", ExpandDottedKeys: true}
+	b, err := formatter.Format(WithFields(Fields{"http.status": 200, "http.method": "GET"}))
+	if err != nil {
+		t.Fatal("Unable to format entry: ", err)
+	}
+	entry := make(map[string]interface{})
+	json.Unmarshal(b, &entry)
+	assert.Equal(t, map[string]interface{}{
+		"http": map[string]interface{}{"status": float64(200), "method": "GET"},
+	}, entry["fields"])
+
+	formatter = &JSONFormatter{ExpandDottedKeys: true}
+	b, _ = formatter.Format(WithFields(Fields{"http.status": 200, "msg": "clash"}))
+	entry = make(map[string]interface{})
+	json.Unmarshal(b, &entry)
+	assert.Equal(t, map[string]interface{}{"status": float64(200)}, entry["http"])
+	assert.Equal(t, "clash", entry["fields.msg"])
+}
@@ -1,6 +1,7 @@
 package logrus
 
 import (
+	"context"
 	"io"
 	"os"
 	"sync"
@@ -27,6 +28,20 @@ type Logger struct {
 	// to) `logrus.Info`, which allows Info(), Warn(), Error() and Fatal() to be
 	// logged. `logrus.Debug` is useful in
 	Level Level
+	// Flag for whether to record the calling method in `Entry.Caller`. Formatters
+	// which support it, such as `ECSFormatter`, include it in their output.
+	// Walking the stack has a cost, so it's disabled by default.
+	ReportCaller bool
+	// The clock telling the time of entries, and the start of elapsed times.
+	// It defaults to `SystemClock` when nil; tests can set a fake one for
+	// reproducible output.
+	Clock Clock
+	// Function called by `Fatal`, `Fatalf` and `Fatalln` with the exit code 1,
+	// even when the level filters the fatal entry out. It defaults to `Exit`
+	// when nil, running the exit handlers and terminating the program. Tests,
+	// or libraries which mustn't terminate the program, can set their own;
+	// the Fatal methods return when it does.
+	ExitFunc func(code int)
 	// Used to sync writing to the log. Locking is enabled by Default
 	mu MutexWrap
 	// Reusable empty entry
@@ -112,6 +127,13 @@ func (logger *Logger) WithError(err error) *Entry {
 	return entry.WithError(err)
 }
 
+// Add a context to the log entry.
+func (logger *Logger) WithContext(ctx context.Context) *Entry {
+	entry := logger.newEntry()
+	defer logger.releaseEntry(entry)
+	return entry.WithContext(ctx)
+}
+
 func (logger *Logger) Debugf(format string, args ...interface{}) {
 	if logger.level() >= DebugLevel {
 		entry := logger.newEntry()
@@ -159,12 +181,9 @@ func (logger *Logger) Errorf(format string, args ...interface{}) {
 }
 
 func (logger *Logger) Fatalf(format string, args ...interface{}) {
-	if logger.level() >= FatalLevel {
-		entry := logger.newEntry()
-		entry.Fatalf(format, args...)
-		logger.releaseEntry(entry)
-	}
-	Exit(1)
+	entry := logger.newEntry()
+	entry.Fatalf(format, args...)
+	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Panicf(format string, args ...interface{}) {
@@ -222,12 +241,9 @@ func (logger *Logger) Error(args ...interface{}) {
 }
 
 func (logger *Logger) Fatal(args ...interface{}) {
-	if logger.level() >= FatalLevel {
-		entry := logger.newEntry()
-		entry.Fatal(args...)
-		logger.releaseEntry(entry)
-	}
-	Exit(1)
+	entry := logger.newEntry()
+	entry.Fatal(args...)
+	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Panic(args ...interface{}) {
@@ -285,12 +301,9 @@ func (logger *Logger) Errorln(args ...interface{}) {
 }
 
 func (logger *Logger) Fatalln(args ...interface{}) {
-	if logger.level() >= FatalLevel {
-		entry := logger.newEntry()
-		entry.Fatalln(args...)
-		logger.releaseEntry(entry)
-	}
-	Exit(1)
+	entry := logger.newEntry()
+	entry.Fatalln(args...)
+	logger.releaseEntry(entry)
 }
 
 func (logger *Logger) Panicln(args ...interface{}) {
@@ -308,6 +321,52 @@ func (logger *Logger) SetNoLock() {
 	logger.mu.Disable()
 }
 
+// SetReportCaller enables or disables recording of the calling method.
+func (logger *Logger) SetReportCaller(reportCaller bool) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.ReportCaller = reportCaller
+}
+
+// SetClock sets the clock telling the time of entries.
+func (logger *Logger) SetClock(clock Clock) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.Clock = clock
+}
+
+// SetExitFunc sets the function called by the Fatal methods.
+func (logger *Logger) SetExitFunc(exitFunc func(code int)) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.ExitFunc = exitFunc
+}
+
+// settings returns the clock of logger, or SystemClock when it has none, and
+// whether it reports callers. Both are read under a single lock, once for
+// each entry.
+func (logger *Logger) settings() (clock Clock, reportCaller bool) {
+	logger.mu.Lock()
+	clock, reportCaller = logger.Clock, logger.ReportCaller
+	logger.mu.Unlock()
+	if clock == nil {
+		clock = SystemClock
+	}
+	return clock, reportCaller
+}
+
+// exit calls the ExitFunc of logger, or Exit when it has none.
+func (logger *Logger) exit(code int) {
+	logger.mu.Lock()
+	exitFunc := logger.ExitFunc
+	logger.mu.Unlock()
+	if exitFunc == nil {
+		Exit(code)
+		return
+	}
+	exitFunc(code)
+}
+
 func (logger *Logger) level() Level {
 	return Level(atomic.LoadUint32((*uint32)(&logger.Level)))
 }
@@ -343,6 +343,64 @@ func TestLoggingRace(t *testing.T) {
 	wg.Wait()
 }
 
+func TestSetReportCallerRace(t *testing.T) {
+	logger := New()
+	logger.Out = &bytes.Buffer{}
+
+	var wg sync.WaitGroup
+	wg.Add(100)
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				logger.SetReportCaller(i%4 == 0)
+			} else {
+				logger.Info("info")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSetClockRace(t *testing.T) {
+	logger := New()
+	logger.Out = &bytes.Buffer{}
+
+	var wg sync.WaitGroup
+	wg.Add(100)
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				logger.SetClock(SystemClock)
+			} else {
+				logger.Info("info")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestSetExitFuncRace(t *testing.T) {
+	logger := New()
+	logger.Out = &bytes.Buffer{}
+
+	var wg sync.WaitGroup
+	wg.Add(100)
+	for i := 0; i < 100; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				logger.SetExitFunc(func(int) {})
+			} else {
+				logger.SetExitFunc(func(int) {})
+				logger.Fatal("fatal")
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 // Compile test
 func TestLogrusInterface(t *testing.T) {
 	var buffer bytes.Buffer
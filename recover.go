@@ -0,0 +1,66 @@
+package logrus
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Recover recovers a panic of the calling goroutine and logs it at level,
+// with the panic value and the stack of the goroutine, then panics again
+// with the same value if repanic is set. It must be deferred:
+//
+//   func worker(job Job) {
+//     defer log.WithField("job", job.ID).Recover(logrus.ErrorLevel, false)
+//     ...
+//   }
+//
+// Panics of entries logged at PanicLevel are logged already, so they are
+// not logged again.
+func (entry *Entry) Recover(level Level, repanic bool) {
+	if value := recover(); value != nil {
+		entry.LogPanic(value, level)
+		if repanic {
+			panic(value)
+		}
+	}
+}
+
+// Recover recovers a panic of the calling goroutine and logs it, like
+// Entry.Recover. It must be deferred:
+//
+//   defer log.Recover(logrus.ErrorLevel, false)
+func (logger *Logger) Recover(level Level, repanic bool) {
+	if value := recover(); value != nil {
+		entry := logger.newEntry()
+		entry.LogPanic(value, level)
+		logger.releaseEntry(entry)
+		if repanic {
+			panic(value)
+		}
+	}
+}
+
+// LogPanic logs value, recovered from a panic, at level with the fields
+// "panic", the value printed, and "stack", the stack of the current
+// goroutine. Called from the deferred function which recovered the panic,
+// the stack shows where the panic happened. Errors are added as the error
+// field too. Values of entries logged at PanicLevel, which panicked with
+// them, aren't logged again.
+//
+// Like the methods of the level, logging at FatalLevel exits and logging at
+// PanicLevel panics with the entry.
+func (entry *Entry) LogPanic(value interface{}, level Level) {
+	if _, logged := value.(*Entry); logged {
+		return
+	}
+	if entry.Logger.level() >= level {
+		fields := Fields{"panic": fmt.Sprint(value), "stack": string(debug.Stack())}
+		if err, ok := value.(error); ok {
+			fields[ErrorKey] = err
+		}
+		entry.WithFields(fields).log(level, "Recovered from panic")
+	}
+	if level == FatalLevel {
+		entry.Logger.exit(1)
+	}
+}
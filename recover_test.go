@@ -0,0 +1,116 @@
+package logrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recoverLogger() (*Logger, *bytes.Buffer) {
+	var buffer bytes.Buffer
+	logger := New()
+	logger.Out = &buffer
+	logger.Formatter = &JSONFormatter{}
+	return logger, &buffer
+}
+
+func decodeEntries(t *testing.T, buffer *bytes.Buffer) []Fields {
+	var entries []Fields
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := make(Fields)
+		assert.NoError(t, json.Unmarshal([]byte(line), &fields))
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+func panicking(value interface{}) {
+	panic(value)
+}
+
+func TestLoggerRecover(t *testing.T) {
+	logger, buffer := recoverLogger()
+
+	func() {
+		defer logger.Recover(ErrorLevel, false)
+		panicking("walrus escaped")
+	}()
+
+	entries := decodeEntries(t, buffer)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "error", entries[0]["level"])
+		assert.Equal(t, "Recovered from panic", entries[0]["msg"])
+		assert.Equal(t, "walrus escaped", entries[0]["panic"])
+		assert.Contains(t, entries[0]["stack"], "logrus.panicking")
+	}
+}
+
+func TestEntryRecoverRepanic(t *testing.T) {
+	logger, buffer := recoverLogger()
+	failure := errors.New("tusk broken")
+
+	assert.PanicsWithValue(t, failure, func() {
+		defer logger.WithField("job", 42).Recover(WarnLevel, true)
+		panicking(failure)
+	})
+
+	entries := decodeEntries(t, buffer)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "warning", entries[0]["level"])
+		assert.Equal(t, float64(42), entries[0]["job"])
+		assert.Equal(t, "tusk broken", entries[0]["panic"])
+		assert.Equal(t, "tusk broken", entries[0]["error"])
+	}
+}
+
+func TestRecoverPanicLevelEntry(t *testing.T) {
+	logger, buffer := recoverLogger()
+
+	func() {
+		defer logger.Recover(ErrorLevel, false)
+		logger.WithField("animal", "walrus").Panic("The ice breaks!")
+	}()
+
+	entries := decodeEntries(t, buffer)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "panic", entries[0]["level"])
+		assert.Equal(t, "The ice breaks!", entries[0]["msg"])
+	}
+}
+
+func TestRecoverFilteredAndFatal(t *testing.T) {
+	logger, buffer := recoverLogger()
+	var codes []int
+	logger.ExitFunc = func(code int) { codes = append(codes, code) }
+
+	func() {
+		defer logger.Recover(DebugLevel, false)
+		panicking("filtered out")
+	}()
+	assert.Equal(t, 0, buffer.Len())
+
+	func() {
+		defer logger.Recover(FatalLevel, false)
+		panicking("fatal")
+	}()
+	assert.Equal(t, []int{1}, codes)
+	entries := decodeEntries(t, buffer)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "fatal", entries[0]["level"])
+	}
+}
+
+func TestRecoverNoPanic(t *testing.T) {
+	logger, buffer := recoverLogger()
+	func() {
+		defer logger.Recover(ErrorLevel, true)
+	}()
+	assert.Equal(t, 0, buffer.Len())
+}
@@ -0,0 +1,199 @@
+package logrus
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+)
+
+// Flusher is implemented by asynchronous hooks and buffered writers, such as
+// bufio.Writer, which can send what they hold on demand. Hooks wrapping
+// others, like the spool hook, only consider entries delivered once Flush
+// succeeds.
+type Flusher interface {
+	Flush() error
+}
+
+// SignalConfig configures HandleSignals.
+type SignalConfig struct {
+	// Logger logging the shutdown, whose hooks and output are flushed.
+	// Defaults to the standard logger.
+	Logger *Logger
+	// Signals triggering the shutdown. Defaults to SIGTERM and SIGINT.
+	Signals []os.Signal
+	// ExitCode the program exits with after a shutdown signal.
+	ExitCode int
+	// Message of the entry logged on shutdown. Defaults to "Shutting down".
+	Message string
+	// Flushers flushed on shutdown, along with the hooks and the output of
+	// Logger which implement Flusher.
+	Flushers []Flusher
+	// LevelSignals enables changing the level of Logger with SIGUSR1, more
+	// verbose, and SIGUSR2, less verbose, between Error and Debug. It has no
+	// effect on Windows.
+	LevelSignals bool
+}
+
+// HandleSignals starts handling signals for a graceful shutdown: on SIGTERM
+// or SIGINT, it logs an entry with the signal, runs the exit handlers,
+// flushes the hooks and output of the logger, then exits with the ExitCode
+// through the ExitFunc of the logger. Exit handlers which fail are logged
+// before flushing. A second shutdown signal exits at once.
+//
+//   stop := logrus.HandleSignals(logrus.SignalConfig{LevelSignals: true})
+//   defer stop()
+//
+// Calling the returned function stops handling the signals.
+func HandleSignals(config SignalConfig) (stop func()) {
+	if config.Logger == nil {
+		config.Logger = std
+	}
+	if len(config.Signals) == 0 {
+		config.Signals = shutdownSignals
+	}
+	if config.Message == "" {
+		config.Message = "Shutting down"
+	}
+
+	shutdown := make(chan os.Signal, 2)
+	signal.Notify(shutdown, config.Signals...)
+	// signal.Notify drops signals arriving while the channel is full, so
+	// leave room for bursts of level signals
+	levels := make(chan os.Signal, 16)
+	if config.LevelSignals && len(levelSignals) == 2 {
+		signal.Notify(levels, levelSignals...)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-shutdown:
+				go func() {
+					// A second signal exits at once
+					select {
+					case <-shutdown:
+						config.Logger.exit(config.ExitCode)
+					case <-done:
+					}
+				}()
+				shutdownOn(config, sig)
+				return
+			case sig := <-levels:
+				changeLevel(config.Logger, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(shutdown)
+			signal.Stop(levels)
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+func shutdownOn(config SignalConfig, sig os.Signal) {
+	logger := config.Logger
+	logger.WithFields(Fields{"signal": sig.String(), "exit_code": config.ExitCode}).Info(config.Message)
+
+	for _, report := range RunExitHandlers(context.Background()) {
+		if report.Err == nil {
+			continue
+		}
+		entry := logger.WithFields(Fields{"handler": report.Name, "duration": report.Duration.String()}).WithError(report.Err)
+		if report.TimedOut {
+			entry.Error("Exit handler timed out")
+		} else {
+			entry.Error("Exit handler failed")
+		}
+	}
+
+	for _, flusher := range loggerFlushers(logger, config.Flushers) {
+		if err := flusher.Flush(); err != nil {
+			logger.mu.Lock()
+			fmt.Fprintf(os.Stderr, "Failed to flush on shutdown, %v\n", err)
+			logger.mu.Unlock()
+		}
+	}
+
+	logger.exit(config.ExitCode)
+}
+
+// loggerFlushers returns flushers, then the hooks and output of logger which
+// implement Flusher, each once.
+func loggerFlushers(logger *Logger, flushers []Flusher) []Flusher {
+	var all []Flusher
+	seen := func(f Flusher) bool {
+		if !reflect.TypeOf(f).Comparable() {
+			return false
+		}
+		for _, other := range all {
+			if reflect.TypeOf(other).Comparable() && other == f {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(v interface{}) {
+		if f, ok := v.(Flusher); ok && !seen(f) {
+			all = append(all, f)
+		}
+	}
+
+	for _, f := range flushers {
+		add(f)
+	}
+	logger.mu.Lock()
+	for _, level := range AllLevels {
+		for _, hook := range logger.Hooks[level] {
+			add(hook)
+		}
+	}
+	add(logger.Out)
+	logger.mu.Unlock()
+	return all
+}
+
+// changeLevel makes logger more verbose on the first level signal, and less
+// verbose on the second, between ErrorLevel and DebugLevel. The change is
+// logged at the new level, so that it shows.
+func changeLevel(logger *Logger, sig os.Signal) {
+	old := logger.level()
+	n := int(old)
+	if sig == levelSignals[0] {
+		n++
+	} else {
+		n--
+	}
+	if n < int(ErrorLevel) {
+		n = int(ErrorLevel)
+	}
+	if n > int(DebugLevel) {
+		n = int(DebugLevel)
+	}
+	level := Level(n)
+	logger.setLevel(level)
+
+	entry := logger.WithFields(Fields{"signal": sig.String(), "old_level": old.String(), "new_level": level.String()})
+	switch level {
+	case DebugLevel:
+		entry.Debug("Log level changed")
+	case InfoLevel:
+		entry.Info("Log level changed")
+	case WarnLevel:
+		entry.Warn("Log level changed")
+	default:
+		entry.Error("Log level changed")
+	}
+}
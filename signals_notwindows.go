@@ -0,0 +1,13 @@
+// +build !windows
+
+package logrus
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	shutdownSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	levelSignals    = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+)
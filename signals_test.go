@@ -0,0 +1,138 @@
+// +build !windows
+
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type flushCounter struct {
+	mu      sync.Mutex
+	flushes int
+}
+
+func (f *flushCounter) Levels() []Level         { return AllLevels }
+func (f *flushCounter) Fire(entry *Entry) error { return nil }
+
+func (f *flushCounter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushes++
+	return nil
+}
+
+func loggedOutput(logger *Logger, buffer *bytes.Buffer) string {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	return buffer.String()
+}
+
+// sendLevelSignal sends sig to the process and waits for the level change it
+// logs, the changes-th, so that signals are never sent back to back.
+func sendLevelSignal(t *testing.T, logger *Logger, buffer *bytes.Buffer, sig syscall.Signal, changes int, level Level) {
+	syscall.Kill(syscall.Getpid(), sig)
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(loggedOutput(logger, buffer), "Log level changed") < changes {
+		if time.Now().After(deadline) {
+			t.Fatalf("level change %d not logged after %v", changes, sig)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if logger.level() != level {
+		t.Fatalf("level is %v, expected %v", logger.level(), level)
+	}
+}
+
+func TestHandleSignalsLevels(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New()
+	logger.Out = &buffer
+	logger.Formatter = &JSONFormatter{}
+
+	stop := HandleSignals(SignalConfig{Logger: logger, LevelSignals: true})
+	defer stop()
+
+	for i, step := range []struct {
+		sig   syscall.Signal
+		level Level
+	}{
+		{syscall.SIGUSR1, DebugLevel},
+		{syscall.SIGUSR1, DebugLevel},
+		{syscall.SIGUSR2, InfoLevel},
+		{syscall.SIGUSR2, WarnLevel},
+		{syscall.SIGUSR2, ErrorLevel},
+		{syscall.SIGUSR2, ErrorLevel},
+	} {
+		sendLevelSignal(t, logger, &buffer, step.sig, i+1, step.level)
+	}
+
+	stop()
+	output := loggedOutput(logger, &buffer)
+	if !strings.Contains(output, `"level":"debug","msg":"Log level changed","new_level":"debug","old_level":"info","signal":"user defined signal 1"`) {
+		t.Fatalf("level change not logged: %s", output)
+	}
+	if !strings.Contains(output, `"level":"error","msg":"Log level changed","new_level":"error","old_level":"warning"`) {
+		t.Fatalf("level change not logged: %s", output)
+	}
+}
+
+func TestHandleSignalsShutdown(t *testing.T) {
+	defer resetExitHandlers()()
+	var buffer bytes.Buffer
+	exits := make(chan int, 2)
+	logger := New()
+	logger.Out = &buffer
+	logger.Formatter = &JSONFormatter{}
+	logger.ExitFunc = func(code int) {
+		exits <- code
+	}
+	hook := &flushCounter{}
+	logger.Hooks.Add(hook)
+	extra := &flushCounter{}
+
+	ran := make(chan struct{}, 1)
+	RegisterExitHandlerContext("closer", 0, func(context.Context) error {
+		ran <- struct{}{}
+		return nil
+	})
+	RegisterExitHandlerContext("failing", 0, func(context.Context) error {
+		return errors.New("db is gone")
+	})
+
+	stop := HandleSignals(SignalConfig{Logger: logger, ExitCode: 3, Flushers: []Flusher{extra, hook}})
+	defer stop()
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	select {
+	case code := <-exits:
+		if code != 3 {
+			t.Fatalf("exited with %d, expected 3", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("didn't exit")
+	}
+	stop()
+
+	select {
+	case <-ran:
+	default:
+		t.Fatalf("exit handler didn't run")
+	}
+	if hook.flushes != 1 || extra.flushes != 1 {
+		t.Fatalf("flushed hook %d times and extra flusher %d times, expected once", hook.flushes, extra.flushes)
+	}
+	output := buffer.String()
+	if !strings.Contains(output, `"exit_code":3,"level":"info","msg":"Shutting down","signal":"terminated"`) {
+		t.Fatalf("shutdown not logged: %s", output)
+	}
+	if !strings.Contains(output, `"error":"db is gone","handler":"failing","level":"error","msg":"Exit handler failed"`) {
+		t.Fatalf("failing exit handler not logged: %s", output)
+	}
+}
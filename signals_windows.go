@@ -0,0 +1,14 @@
+// +build windows
+
+package logrus
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	shutdownSignals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	// Windows has no user-defined signals
+	levelSignals []os.Signal
+)
@@ -0,0 +1,332 @@
+package logrus
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultPattern is the pattern used by a TemplateFormatter with neither a
+// Template nor a Pattern set.
+const DefaultPattern = "%time %-7level %msg %fields"
+
+// TemplateFormatter formats entries with a Go `text/template`, or with a
+// compact pattern translated into one. The template is executed with the
+// `*Entry` being formatted, so `.Time`, `.Level`, `.Message`, `.Data` and
+// `.Caller` are available, along with these functions:
+//
+//  pad WIDTH VALUE         pad to WIDTH columns, left aligned if WIDTH is negative
+//  truncate N VALUE        cut to at most N characters
+//  upper VALUE, lower VALUE
+//  color NAME VALUE        colorize with red, green, yellow, blue, magenta, cyan or gray
+//  levelColor LEVEL VALUE  colorize with the color TextFormatter uses for LEVEL
+//  timestamp TIME          format using TimestampFormat
+//  formatTime LAYOUT TIME  format using LAYOUT
+//  field DATA KEY          the value of a single field, or "" if it's not set
+//  fields DATA KEYS...     only the given fields
+//  omit DATA KEYS...       all but the given fields
+//  kv DATA                 fields as sorted key=value pairs, quoted as by TextFormatter
+//  caller FRAME            file:line of the caller, "" unless ReportCaller is set
+//  callerFunc FRAME        function name of the caller
+//
+// The value comes last, so functions can be chained in pipelines:
+//
+//  {{timestamp .Time}} {{levelColor .Level (pad -7 .Level)}} {{.Message | truncate 40 | pad -40}} {{kv (omit .Data "password")}}
+//
+// Colors are only output when a TTY is attached, unless ForceColors is set.
+// Templates are compiled once and may be used concurrently. Trailing blanks
+// are trimmed and a newline is appended to every line.
+type TemplateFormatter struct {
+	// Template is the `text/template` source used to format entries.
+	Template string
+
+	// Pattern is used when Template is empty, and defaults to DefaultPattern.
+	// It's made of literal text and printf-like verbs with an optional width
+	// and precision, e.g. `%time %-7level %.40msg %fields`. The verbs are
+	// %time, %level, %LEVEL (upper-cased), %msg, %fields, %caller, %func,
+	// %{key} for a single field and %% for a literal percent sign.
+	Pattern string
+
+	// TimestampFormat used by the `timestamp` function and `%time`.
+	TimestampFormat string
+
+	// Set to true to bypass checking for a TTY before outputting colors.
+	ForceColors bool
+
+	// Force disabling colors.
+	DisableColors bool
+
+	// Funcs are added to, or override, the functions available in the
+	// template.
+	Funcs template.FuncMap
+
+	tmpl       *template.Template
+	compileErr error
+	isColored  bool
+
+	sync.Once
+}
+
+// NewTemplateFormatter compiles text into a TemplateFormatter, reporting
+// syntax errors upfront rather than on the first entry formatted.
+func NewTemplateFormatter(text string) (*TemplateFormatter, error) {
+	f := &TemplateFormatter{Template: text}
+	f.tmpl, f.compileErr = f.compile()
+	return f, f.compileErr
+}
+
+// NewPatternFormatter compiles pattern into a TemplateFormatter, see
+// TemplateFormatter.Pattern for the syntax.
+func NewPatternFormatter(pattern string) (*TemplateFormatter, error) {
+	f := &TemplateFormatter{Pattern: pattern}
+	f.tmpl, f.compileErr = f.compile()
+	return f, f.compileErr
+}
+
+func (f *TemplateFormatter) init(entry *Entry) {
+	if f.tmpl == nil && f.compileErr == nil {
+		f.tmpl, f.compileErr = f.compile()
+	}
+
+	isTerminal := false
+	if entry.Logger != nil {
+		isTerminal = IsTerminal(entry.Logger.Out)
+	}
+	f.isColored = (f.ForceColors || isTerminal) && !f.DisableColors
+}
+
+func (f *TemplateFormatter) Format(entry *Entry) ([]byte, error) {
+	f.Do(func() { f.init(entry) })
+	if f.compileErr != nil {
+		return nil, f.compileErr
+	}
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+
+	if err := f.tmpl.Execute(b, entry); err != nil {
+		return nil, fmt.Errorf("Failed to execute log template, %v", err)
+	}
+
+	line := bytes.TrimRight(b.Bytes(), " \t\n")
+	b.Truncate(len(line))
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+func (f *TemplateFormatter) compile() (*template.Template, error) {
+	text := f.Template
+	if text == "" {
+		pattern := f.Pattern
+		if pattern == "" {
+			pattern = DefaultPattern
+		}
+		var err error
+		if text, err = compilePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpl, err := template.New("logrus").Funcs(f.funcs()).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse log template, %v", err)
+	}
+	return tmpl, nil
+}
+
+var templateColors = map[string]int{
+	"red":     red,
+	"green":   green,
+	"yellow":  yellow,
+	"blue":    blue,
+	"magenta": 35,
+	"cyan":    36,
+	"gray":    gray,
+}
+
+func (f *TemplateFormatter) funcs() template.FuncMap {
+	colorize := func(color int, v interface{}) string {
+		if !f.isColored {
+			return fmt.Sprint(v)
+		}
+		return fmt.Sprintf("\x1b[%dm%v\x1b[0m", color, v)
+	}
+	tf := &TextFormatter{QuoteCharacter: "\""}
+
+	funcs := template.FuncMap{
+		"pad": func(width int, v interface{}) string {
+			return fmt.Sprintf("%*v", width, v)
+		},
+		"truncate": func(n int, v interface{}) string {
+			s := fmt.Sprint(v)
+			if utf8.RuneCountInString(s) <= n {
+				return s
+			}
+			return string([]rune(s)[:n])
+		},
+		"upper": func(v interface{}) string {
+			return strings.ToUpper(fmt.Sprint(v))
+		},
+		"lower": func(v interface{}) string {
+			return strings.ToLower(fmt.Sprint(v))
+		},
+		"color": func(name string, v interface{}) (string, error) {
+			color, ok := templateColors[name]
+			if !ok {
+				return "", fmt.Errorf("unknown color %q", name)
+			}
+			return colorize(color, v), nil
+		},
+		"levelColor": func(level Level, v interface{}) string {
+			return colorize(levelColor(level), v)
+		},
+		"timestamp": func(t time.Time) string {
+			timestampFormat := f.TimestampFormat
+			if timestampFormat == "" {
+				timestampFormat = DefaultTimestampFormat
+			}
+			return t.Format(timestampFormat)
+		},
+		"formatTime": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"field": func(data Fields, key string) interface{} {
+			if v, ok := data[key]; ok {
+				return v
+			}
+			return ""
+		},
+		"fields": func(data Fields, keys ...string) Fields {
+			selected := make(Fields, len(keys))
+			for _, k := range keys {
+				if v, ok := data[k]; ok {
+					selected[k] = v
+				}
+			}
+			return selected
+		},
+		"omit": func(data Fields, keys ...string) Fields {
+			selected := make(Fields, len(data))
+			for k, v := range data {
+				selected[k] = v
+			}
+			for _, k := range keys {
+				delete(selected, k)
+			}
+			return selected
+		},
+		"kv": func(data Fields) string {
+			keys := make([]string, 0, len(data))
+			for k := range data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			b := &bytes.Buffer{}
+			for _, k := range keys {
+				tf.appendKeyValue(b, k, data[k])
+			}
+			return strings.TrimSuffix(b.String(), " ")
+		},
+		"caller": func(frame *runtime.Frame) string {
+			if frame == nil {
+				return ""
+			}
+			return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		},
+		"callerFunc": func(frame *runtime.Frame) string {
+			if frame == nil {
+				return ""
+			}
+			return frame.Function
+		},
+	}
+	for name, fn := range f.Funcs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+var patternVerb = regexp.MustCompile(`%(?:%|(-?[0-9]+)?(?:\.([0-9]+))?(time|level|LEVEL|msg|fields|caller|func|\{[^}]*\}))`)
+
+// compilePattern translates a pattern into the equivalent template.
+func compilePattern(pattern string) (string, error) {
+	var b bytes.Buffer
+	literal := func(s string) error {
+		if strings.Contains(s, "%") {
+			return fmt.Errorf("Failed to parse log pattern, bad verb in %q", s)
+		}
+		if strings.Contains(s, "{{") {
+			s = "{{" + strconv.Quote(s) + "}}"
+		}
+		b.WriteString(s)
+		return nil
+	}
+
+	last := 0
+	for _, m := range patternVerb.FindAllStringSubmatchIndex(pattern, -1) {
+		if err := literal(pattern[last:m[0]]); err != nil {
+			return "", err
+		}
+		last = m[1]
+		if pattern[m[0]:m[1]] == "%%" {
+			b.WriteString("%")
+			continue
+		}
+
+		width, precision, verb := "", "", pattern[m[6]:m[7]]
+		if m[2] >= 0 {
+			width = pattern[m[2]:m[3]]
+		}
+		if m[4] >= 0 {
+			precision = pattern[m[4]:m[5]]
+		}
+
+		var expr string
+		switch verb {
+		case "time":
+			expr = "timestamp .Time"
+		case "level":
+			expr = "print .Level"
+		case "LEVEL":
+			expr = "upper .Level"
+		case "msg":
+			expr = ".Message"
+		case "fields":
+			expr = "kv .Data"
+		case "caller":
+			expr = "caller .Caller"
+		case "func":
+			expr = "callerFunc .Caller"
+		default:
+			expr = "field .Data " + strconv.Quote(verb[1:len(verb)-1])
+		}
+		if precision != "" {
+			expr = "truncate " + precision + " (" + expr + ")"
+		}
+		if width != "" {
+			expr = "pad " + width + " (" + expr + ")"
+		}
+		if verb == "level" || verb == "LEVEL" {
+			expr = "levelColor .Level (" + expr + ")"
+		}
+		b.WriteString("{{" + expr + "}}")
+	}
+	if err := literal(pattern[last:]); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,145 @@
+package logrus
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func templateEntry() *Entry {
+	entry := WithFields(Fields{"animal": "walrus", "size": 10, "note": "big one"})
+	entry.Time = time.Date(2017, 5, 6, 8, 48, 33, 0, time.UTC)
+	entry.Level = WarnLevel
+	entry.Message = "A walrus appears"
+	return entry
+}
+
+func TestPatternFormatter(t *testing.T) {
+	f, err := NewPatternFormatter("%time [%-7level] %.8msg %{animal}|%{missing}| %fields %%")
+	assert.NoError(t, err)
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, `2017-05-06T08:48:33Z [warning] A walrus walrus|| animal=walrus note="big one" size=10 %`+"\n", string(b))
+}
+
+func TestPatternFormatterWidths(t *testing.T) {
+	f, err := NewPatternFormatter("%5LEVEL|%-6caller|%.3func|%{a}")
+	assert.NoError(t, err)
+
+	entry := templateEntry()
+	entry.Level = InfoLevel
+	entry.Caller = &runtime.Frame{File: "/src/x.go", Line: 7, Function: "main.main"}
+
+	b, err := f.Format(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, " INFO|x.go:7|mai|\n", string(b))
+}
+
+func TestPatternFormatterDefault(t *testing.T) {
+	f := &TemplateFormatter{}
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, `2017-05-06T08:48:33Z warning A walrus appears animal=walrus note="big one" size=10`+"\n", string(b))
+}
+
+func TestPatternFormatterInvalid(t *testing.T) {
+	_, err := NewPatternFormatter("%time %bogus")
+	assert.Error(t, err)
+
+	f := &TemplateFormatter{Pattern: "%nope"}
+	_, err = f.Format(templateEntry())
+	assert.Error(t, err)
+}
+
+func TestPatternFormatterBraces(t *testing.T) {
+	f, err := NewPatternFormatter("{{ %msg }}")
+	assert.NoError(t, err)
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, "{{ A walrus appears }}\n", string(b))
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	f, err := NewTemplateFormatter(`{{formatTime "15:04" .Time}} {{upper .Level | truncate 4}} {{.Message | lower}} {{kv (omit .Data "note")}} {{kv (fields .Data "note" "missing")}}`)
+	assert.NoError(t, err)
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, `08:48 WARN a walrus appears animal=walrus size=10 note="big one"`+"\n", string(b))
+}
+
+func TestTemplateFormatterColors(t *testing.T) {
+	f, err := NewTemplateFormatter(`{{levelColor .Level "x"}} {{color "cyan" .Message}}`)
+	assert.NoError(t, err)
+	f.ForceColors = true
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, "\x1b[33mx\x1b[0m \x1b[36mA walrus appears\x1b[0m\n", string(b))
+
+	f, err = NewTemplateFormatter(`{{color "cyan" .Message}}`)
+	assert.NoError(t, err)
+	b, err = f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, "A walrus appears\n", string(b))
+
+	f, err = NewTemplateFormatter(`{{color "plaid" .Message}}`)
+	assert.NoError(t, err)
+	_, err = f.Format(templateEntry())
+	assert.Error(t, err)
+}
+
+func TestTemplateFormatterFuncs(t *testing.T) {
+	f := &TemplateFormatter{
+		Template: `{{shout .Message}}`,
+		Funcs: map[string]interface{}{
+			"shout": func(s string) string { return s + "!" },
+		},
+	}
+
+	b, err := f.Format(templateEntry())
+	assert.NoError(t, err)
+	assert.Equal(t, "A walrus appears!\n", string(b))
+}
+
+func TestTemplateFormatterErrors(t *testing.T) {
+	_, err := NewTemplateFormatter(`{{.Message`)
+	assert.Error(t, err)
+
+	f, err := NewTemplateFormatter(`{{.Nope}}`)
+	assert.NoError(t, err)
+	_, err = f.Format(templateEntry())
+	assert.Error(t, err)
+
+	f, _ = NewTemplateFormatter(`{{kv .Data}}`)
+	b, err := f.Format(WithError(errors.New("wild walrus")))
+	assert.NoError(t, err)
+	assert.Equal(t, "error=\"wild walrus\"\n", string(b))
+}
+
+func TestTemplateFormatterConcurrent(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New()
+	logger.Out = &buffer
+	logger.Formatter = &TemplateFormatter{Pattern: "%level %msg %fields"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.WithField("i", i).Info("concurrent")
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, bytes.Count(buffer.Bytes(), []byte("info concurrent i=")))
+}
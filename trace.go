@@ -0,0 +1,220 @@
+package logrus
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Default keys of the trace correlation fields added by TraceHook. They can
+// be changed through `TraceHook.FieldMap`.
+const (
+	FieldKeyTraceID    = "trace_id"
+	FieldKeySpanID     = "span_id"
+	FieldKeyTraceFlags = "trace_flags"
+)
+
+// TraceContext identifies the trace and span an entry was logged in, as
+// defined by the W3C Trace Context specification.
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether both the trace and the span ID are set. The
+// specification forbids all zero IDs.
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != [16]byte{} && tc.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the sampled flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 != 0
+}
+
+// String returns the trace context in the `traceparent` header format, e.g.
+// `00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`.
+func (tc TraceContext) String() string {
+	return "00-" + hex.EncodeToString(tc.TraceID[:]) + "-" + hex.EncodeToString(tc.SpanID[:]) + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// ParseTraceparent parses the value of a W3C `traceparent` header.
+func ParseTraceparent(traceparent string) (TraceContext, error) {
+	var tc TraceContext
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tc, errors.New("malformed traceparent")
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff {
+		return tc, errors.New("invalid traceparent version")
+	}
+	// Version 00 has exactly four parts, later versions may append more.
+	if version[0] == 0 && len(parts) != 4 {
+		return tc, errors.New("malformed traceparent")
+	}
+	if _, err := hex.Decode(tc.TraceID[:], []byte(parts[1])); err != nil || parts[1] != strings.ToLower(parts[1]) {
+		return tc, errors.New("invalid traceparent trace-id")
+	}
+	if _, err := hex.Decode(tc.SpanID[:], []byte(parts[2])); err != nil || parts[2] != strings.ToLower(parts[2]) {
+		return tc, errors.New("invalid traceparent parent-id")
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return tc, errors.New("invalid traceparent trace-flags")
+	}
+	tc.Flags = flags[0]
+	if !tc.IsValid() {
+		return tc, errors.New("invalid traceparent, all zero trace-id or parent-id")
+	}
+
+	return tc, nil
+}
+
+type traceContextKey struct{}
+
+// ContextWithTrace returns a copy of ctx carrying the trace context, to be
+// picked up by ContextTraceExtractor.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context stored in ctx by
+// ContextWithTrace.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
+
+// A TraceExtractor finds the trace context of an entry.
+type TraceExtractor interface {
+	ExtractTrace(entry *Entry) (TraceContext, bool)
+}
+
+// The TraceExtractorFunc type is an adapter to allow the use of ordinary
+// functions as trace extractors.
+type TraceExtractorFunc func(entry *Entry) (TraceContext, bool)
+
+func (f TraceExtractorFunc) ExtractTrace(entry *Entry) (TraceContext, bool) {
+	return f(entry)
+}
+
+// ContextTraceExtractor extracts the trace context from the context attached
+// to an entry with `WithContext`. With a nil key it reads the trace context
+// stored by ContextWithTrace, otherwise the context value under key, which
+// may either be a TraceContext or a `traceparent` string.
+func ContextTraceExtractor(key interface{}) TraceExtractor {
+	return TraceExtractorFunc(func(entry *Entry) (TraceContext, bool) {
+		if entry.Context == nil {
+			return TraceContext{}, false
+		}
+		if key == nil {
+			return TraceFromContext(entry.Context)
+		}
+		return traceFromValue(entry.Context.Value(key))
+	})
+}
+
+// TraceparentFieldExtractor extracts the trace context from a field holding
+// a `traceparent` header value, for instance one copied from an incoming
+// request with `WithField("traceparent", req.Header.Get("traceparent"))`.
+func TraceparentFieldExtractor(key string) TraceExtractor {
+	return TraceExtractorFunc(func(entry *Entry) (TraceContext, bool) {
+		return traceFromValue(entry.Data[key])
+	})
+}
+
+// ChainTraceExtractors returns an extractor trying each of the extractors in
+// turn, until one of them finds a trace context.
+func ChainTraceExtractors(extractors ...TraceExtractor) TraceExtractor {
+	return TraceExtractorFunc(func(entry *Entry) (TraceContext, bool) {
+		for _, extractor := range extractors {
+			if tc, ok := extractor.ExtractTrace(entry); ok {
+				return tc, true
+			}
+		}
+		return TraceContext{}, false
+	})
+}
+
+func traceFromValue(v interface{}) (TraceContext, bool) {
+	switch v := v.(type) {
+	case TraceContext:
+		return v, v.IsValid()
+	case string:
+		tc, err := ParseTraceparent(v)
+		return tc, err == nil
+	}
+	return TraceContext{}, false
+}
+
+// TraceHook adds the trace and span ID and the trace flags of every entry
+// fired to its fields, using hex encoding as in a `traceparent` header. This
+// correlates logs and traces without adding the fields by hand at every
+// `WithFields` call:
+//
+//  log.AddHook(logrus.NewTraceHook(logrus.ContextTraceExtractor(nil)))
+//  log.WithContext(ctx).Info("A walrus appears")
+//
+// Hooks fire in the order they were added, add the TraceHook first so hooks
+// shipping entries elsewhere see the trace fields.
+type TraceHook struct {
+	// Extractor finds the trace context of the entries. Defaults to
+	// `ContextTraceExtractor(nil)`.
+	Extractor TraceExtractor
+
+	// FieldMap allows users to customize the keys of the trace fields. Set
+	// the same FieldMap as the `TraceFieldMap` of an ECSFormatter.
+	// As an example:
+	// hook := &TraceHook{
+	//   	FieldMap: FieldMap{
+	// 		 FieldKeyTraceID: "trace.id",
+	// 		 FieldKeySpanID: "span.id",
+	//    },
+	// }
+	FieldMap FieldMap
+
+	// DisableTraceFlags leaves out the trace flags field.
+	DisableTraceFlags bool
+}
+
+// NewTraceHook creates a trace hook using the given extractor.
+func NewTraceHook(extractor TraceExtractor) *TraceHook {
+	return &TraceHook{Extractor: extractor}
+}
+
+func (hook *TraceHook) Levels() []Level {
+	return AllLevels
+}
+
+func (hook *TraceHook) Fire(entry *Entry) error {
+	extractor := hook.Extractor
+	if extractor == nil {
+		extractor = ContextTraceExtractor(nil)
+	}
+	tc, ok := extractor.ExtractTrace(entry)
+	if !ok {
+		return nil
+	}
+
+	// The data is shared with the entry the log call was made on, so it's
+	// copied rather than modified in place.
+	data := make(Fields, len(entry.Data)+3)
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	data[hook.FieldMap.resolve(FieldKeyTraceID)] = hex.EncodeToString(tc.TraceID[:])
+	data[hook.FieldMap.resolve(FieldKeySpanID)] = hex.EncodeToString(tc.SpanID[:])
+	if !hook.DisableTraceFlags {
+		data[hook.FieldMap.resolve(FieldKeyTraceFlags)] = hex.EncodeToString([]byte{tc.Flags})
+	}
+	entry.Data = data
+
+	return nil
+}
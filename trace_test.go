@@ -0,0 +1,117 @@
+package logrus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent(testTraceparent)
+	assert.NoError(t, err)
+	assert.True(t, tc.IsValid())
+	assert.True(t, tc.Sampled())
+	assert.Equal(t, testTraceparent, tc.String())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, err := ParseTraceparent(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err, "future versions may add fields")
+}
+
+func TestTraceHookFromContext(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTrace(context.Background(), tc)
+
+	LogAndAssertJSON(t, func(log *Logger) {
+		log.Hooks.Add(NewTraceHook(ContextTraceExtractor(nil)))
+		log.WithContext(ctx).WithField("animal", "walrus").Info("traced")
+	}, func(fields Fields) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+		assert.Equal(t, "00f067aa0ba902b7", fields["span_id"])
+		assert.Equal(t, "01", fields["trace_flags"])
+		assert.Equal(t, "walrus", fields["animal"])
+	})
+}
+
+func TestTraceHookDefaultExtractor(t *testing.T) {
+	tc, _ := ParseTraceparent(testTraceparent)
+	ctx := ContextWithTrace(context.Background(), tc)
+
+	LogAndAssertJSON(t, func(log *Logger) {
+		log.Hooks.Add(&TraceHook{})
+		log.WithContext(ctx).Info("traced")
+	}, func(fields Fields) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	})
+}
+
+type customTraceKey struct{}
+
+func TestTraceHookExtractors(t *testing.T) {
+	ctx := context.WithValue(context.Background(), customTraceKey{}, testTraceparent)
+
+	LogAndAssertJSON(t, func(log *Logger) {
+		hook := NewTraceHook(ContextTraceExtractor(customTraceKey{}))
+		hook.FieldMap = FieldMap{FieldKeyTraceID: "trace.id", FieldKeySpanID: "span.id"}
+		hook.DisableTraceFlags = true
+		log.Hooks.Add(hook)
+		log.WithContext(ctx).Info("traced")
+	}, func(fields Fields) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace.id"])
+		assert.Equal(t, "00f067aa0ba902b7", fields["span.id"])
+		assert.NotContains(t, fields, "trace_flags")
+	})
+
+	LogAndAssertJSON(t, func(log *Logger) {
+		log.Hooks.Add(NewTraceHook(ChainTraceExtractors(
+			ContextTraceExtractor(nil),
+			TraceparentFieldExtractor("traceparent"),
+		)))
+		log.WithField("traceparent", testTraceparent).Info("traced")
+	}, func(fields Fields) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	})
+
+	LogAndAssertJSON(t, func(log *Logger) {
+		log.Hooks.Add(NewTraceHook(TraceExtractorFunc(func(entry *Entry) (TraceContext, bool) {
+			return TraceContext{}, false
+		})))
+		log.Info("untraced")
+	}, func(fields Fields) {
+		assert.NotContains(t, fields, "trace_id")
+	})
+}
+
+func TestTraceHookDoesNotModifyEntry(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New()
+	logger.Out = &buffer
+	logger.Formatter = new(JSONFormatter)
+	logger.Hooks.Add(NewTraceHook(TraceparentFieldExtractor("traceparent")))
+
+	entry := logger.WithField("traceparent", testTraceparent)
+	entry.Info("traced")
+	assert.Len(t, entry.Data, 1)
+
+	var fields Fields
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &fields))
+	assert.Equal(t, "00f067aa0ba902b7", fields["span_id"])
+}